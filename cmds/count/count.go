@@ -0,0 +1,86 @@
+package count
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/subcommands"
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/proto"
+	"github.com/rs/zerolog"
+)
+
+type Command struct {
+	*shared.Config
+	logger zerolog.Logger
+
+	dirsOnly  bool
+	filesOnly bool
+
+	noIgnore       bool
+	noGlobalIgnore bool
+	hidden         bool
+
+	prefix string
+	root   bool
+}
+
+func (*Command) Name() string     { return "count" }
+func (*Command) Synopsis() string { return "Count the entries read would return" }
+func (*Command) Usage() string {
+	return `count:
+`
+}
+
+func (c *Command) SetFlags(f *flag.FlagSet) {
+	c.Config.SetFlags(f)
+
+	f.StringVar(&c.prefix, "p", "", "Prefix to limit paths counted")
+	f.StringVar(&c.prefix, "prefix", "", "Alias for -p")
+	f.BoolVar(&c.root, "r", false, "Auto discover root")
+	f.BoolVar(&c.dirsOnly, "d", false, "Only count directories")
+	f.BoolVar(&c.filesOnly, "f", false, "Only count files")
+	f.BoolVar(&c.noIgnore, "no-ignore", false, "Also count entries ignored by .gitignore and other ignore files")
+	f.BoolVar(&c.noGlobalIgnore, "no-global-ignore", false, "Also count entries only ignored by the global excludes file")
+	f.BoolVar(&c.hidden, "hidden", false, "Also count hidden entries, starting with '.'")
+}
+
+func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	c.logger = shared.Logger().With().Str("command", "count").Logger()
+
+	if c.filesOnly && c.dirsOnly {
+		return shared.Exitf("-d xor -f; can't give both")
+	}
+
+	if c.root && c.prefix == "" {
+		root, err := shared.FindRoot()
+		if err != nil {
+			return shared.Exitf("Error finding root: %v", err)
+		}
+
+		c.prefix = root
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		return shared.Exitf("Error connecting to fscache: %v", err)
+	}
+
+	counts, err := client.Count(context.Background(), &proto.CountRequest{
+		Prefix:    shared.CleanPrefix(c.prefix),
+		FilesOnly: c.filesOnly,
+		DirsOnly:  c.dirsOnly,
+
+		NoIgnore:       c.noIgnore,
+		NoGlobalIgnore: c.noGlobalIgnore,
+		NoHidden:       !c.hidden,
+	})
+	if err != nil {
+		return shared.Exitf("Error fetching count: %v", err)
+	}
+
+	fmt.Println(counts.Files + counts.Dirs)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,122 @@
+package ignore
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/subcommands"
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/proto"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type Command struct {
+	*shared.Config
+	logger zerolog.Logger
+
+	builtin bool
+}
+
+func (*Command) Name() string     { return "ignore" }
+func (*Command) Synopsis() string { return "Add, remove or list paths never to index" }
+func (*Command) Usage() string {
+	return `ignore add|remove <pattern>...
+ignore list:
+  Patterns are kept in the user ignore file, see run -user-ignore-file, and
+  are matched like .gitignore patterns relative to "/". The running fscache
+  applies a change straight away.
+`
+}
+
+func (c *Command) SetFlags(f *flag.FlagSet) {
+	c.Config.SetFlags(f)
+
+	f.BoolVar(&c.builtin, "builtin", false, "Also list the built in ignores, before the user's")
+}
+
+func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	c.logger = shared.Logger().With().Str("command", "ignore").Logger()
+
+	if f.NArg() == 0 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	action, patterns := f.Arg(0), f.Args()[1:]
+
+	client, err := c.Client()
+	if err != nil {
+		return shared.Exitf("Error connecting to fscache: %v", err)
+	}
+
+	switch action {
+	case "add", "remove":
+		if len(patterns) == 0 {
+			return shared.Exitf("%s needs at least one pattern", action)
+		}
+		return c.change(client, action, patterns)
+	case "list":
+		return List(client, c.builtin)
+	}
+
+	f.Usage()
+	return subcommands.ExitUsageError
+}
+
+// List prints the patterns in the user ignore file of the running fscache,
+// after the built in ones if builtin is set.
+func List(client proto.FSCacheClient, builtin bool) subcommands.ExitStatus {
+	ignores, err := client.ListIgnores(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		return shared.Exitf("Error listing ignores: %v", err)
+	}
+
+	if builtin {
+		for _, p := range ignores.Builtin {
+			fmt.Println(p)
+		}
+	}
+	for _, p := range ignores.Patterns {
+		fmt.Println(p)
+	}
+
+	return subcommands.ExitSuccess
+}
+
+func (c *Command) change(client proto.FSCacheClient, action string, patterns []string) subcommands.ExitStatus {
+	req := &proto.IgnoreRequest{Patterns: patterns}
+
+	var ignores *proto.Ignores
+	var err error
+	if action == "add" {
+		ignores, err = client.AddIgnores(context.Background(), req)
+	} else {
+		ignores, err = client.RemoveIgnores(context.Background(), req)
+	}
+	if err != nil {
+		return shared.Exitf("Error changing ignores: %v", err)
+	}
+
+	changed := map[string]bool{}
+	for _, p := range ignores.Changed {
+		changed[p] = true
+	}
+	for _, p := range patterns {
+		if changed[p] {
+			continue
+		}
+		if action == "add" {
+			fmt.Fprintf(os.Stderr, "%q is already in the ignore file\n", p)
+		} else {
+			fmt.Fprintf(os.Stderr, "%q isn't in the ignore file\n", p)
+		}
+	}
+
+	if ignores.Purged > 0 {
+		fmt.Printf("removed %d entries from the index\n", ignores.Purged)
+	}
+
+	return subcommands.ExitSuccess
+}
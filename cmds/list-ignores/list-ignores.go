@@ -3,11 +3,9 @@ package listignores
 import (
 	"context"
 	"flag"
-	"io"
-	"os"
 
 	"github.com/google/subcommands"
-	"github.com/keyneston/fscache/ignorer"
+	"github.com/keyneston/fscache/cmds/ignore"
 	"github.com/keyneston/fscache/internal/shared"
 	"github.com/rs/zerolog"
 )
@@ -21,6 +19,8 @@ func (*Command) Name() string     { return "list-ignores" }
 func (*Command) Synopsis() string { return "list global ignores" }
 func (*Command) Usage() string {
 	return `list-ignores:
+  Lists the built in ignores followed by the user's, the same as
+  ignore list -builtin.
 `
 }
 
@@ -29,7 +29,12 @@ func (c *Command) SetFlags(f *flag.FlagSet) {
 }
 
 func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	io.Copy(os.Stdout, ignorer.GlobalIgnoreList())
+	c.logger = shared.Logger().With().Str("command", "list-ignores").Logger()
 
-	return subcommands.ExitSuccess
+	client, err := c.Client()
+	if err != nil {
+		return shared.Exitf("Error connecting to fscache: %v", err)
+	}
+
+	return ignore.List(client, true)
 }
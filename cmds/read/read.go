@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"flag"
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -22,7 +21,12 @@ type Command struct {
 	dirsOnly  bool
 	filesOnly bool
 
+	noIgnore       bool
+	noGlobalIgnore bool
+	hidden         bool
+
 	prefix string
+	query  string
 	mode   string
 	root   bool
 
@@ -43,10 +47,14 @@ func (c *Command) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.prefix, "p", "", "Prefix to limit paths returned")
 	f.BoolVar(&c.root, "r", false, "Auto discover root")
 	f.StringVar(&c.prefix, "prefix", "", "Alias for -p")
+	f.StringVar(&c.query, "q", "", "Only return paths matching every term, e.g. \"cache go\"")
 	f.IntVar(&c.limit, "n", 0, "Number of items to return. 0 for all")
 	f.IntVar(&c.batchSize, "b", 1000, "Number of items to return per batch")
 	f.BoolVar(&c.dirsOnly, "d", false, "Only return directories")
 	f.BoolVar(&c.filesOnly, "f", false, "Only return files")
+	f.BoolVar(&c.noIgnore, "no-ignore", false, "Also return entries ignored by .gitignore and other ignore files")
+	f.BoolVar(&c.noGlobalIgnore, "no-global-ignore", false, "Also return entries only ignored by the global excludes file")
+	f.BoolVar(&c.hidden, "hidden", false, "Also return hidden entries, starting with '.'")
 }
 
 func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -58,7 +66,7 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 	}
 
 	if c.root && c.prefix == "" {
-		root, err := findRoot()
+		root, err := shared.FindRoot()
 		if err != nil {
 			return shared.Exitf("Error finding root: %v", err)
 		}
@@ -71,7 +79,7 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		return shared.Exitf("Error connecting to fscache: %v", err)
 	}
 
-	c.prefix = cleanPrefix(c.prefix)
+	c.prefix = shared.CleanPrefix(c.prefix)
 
 	if c.filesOnly && c.dirsOnly {
 		return shared.Exitf("-d xor -f; can't give both")
@@ -83,7 +91,12 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		BatchSize:  int32(c.batchSize),
 		FilesOnly:  c.filesOnly,
 		DirsOnly:   c.dirsOnly,
-		CurrentDir: cleanPrefix(cwd),
+		CurrentDir: shared.CleanPrefix(cwd),
+		Query:      c.query,
+
+		NoIgnore:       c.noIgnore,
+		NoGlobalIgnore: c.noGlobalIgnore,
+		NoHidden:       !c.hidden,
 	})
 	if err != nil {
 		return shared.Exitf("Error fetching results: %v", err)
@@ -118,57 +131,3 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 
 	return subcommands.ExitSuccess
 }
-
-// cleanPrefix adds a trailing '/' to a prefix if it is set and it doesn't have
-// one
-func cleanPrefix(prefix string) string {
-	if prefix == "" {
-		return ""
-	}
-
-	if prefix[len(prefix)-1] != '/' {
-		prefix = fmt.Sprintf("%s/", prefix)
-	}
-	return prefix
-}
-
-var roots = map[string]bool{
-	".git": true,
-	".svn": true,
-	".hg":  true,
-}
-
-func findRoot() (string, error) {
-	dir, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-
-	for {
-		if dir == "/" {
-			return dir, nil
-		}
-
-		files, err := os.ReadDir(dir)
-		if err != nil {
-			return "", err
-		}
-
-		for _, f := range files {
-			name := f.Name()
-
-			// Only check dot files. These (should?!?) come before non dot
-			// files, so abort as soon as we hit non dot files
-			if name[0] != '.' {
-				break
-			}
-			if _, ok := roots[name]; ok {
-				return dir, nil
-			}
-		}
-
-		dir = filepath.Dir(dir)
-	}
-
-	return "/", nil
-}
@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/google/subcommands"
+	"github.com/keyneston/fscache/fscache"
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/internal/shared"
+)
+
+type Command struct {
+	*shared.Config
+
+	mode      string
+	raw       bool
+	dirsOnly  bool
+	filesOnly bool
+}
+
+func (*Command) Name() string     { return "replay" }
+func (*Command) Synopsis() string { return "Replay a recording from run -record and print the index" }
+func (*Command) Usage() string {
+	return `replay [flags] <file>:
+  Apply a recording from "run -record" to an empty index and print every
+  entry in it. Use "-" to read the recording from stdin.
+`
+}
+
+func (c *Command) SetFlags(f *flag.FlagSet) {
+	c.Config.SetFlags(f)
+
+	f.StringVar(&c.mode, "mode", "pebble", "DB mode; experimental")
+	f.BoolVar(&c.raw, "raw", false, "Apply events as recorded, without coalescing each batch")
+	f.BoolVar(&c.dirsOnly, "d", false, "Only print directories")
+	f.BoolVar(&c.filesOnly, "f", false, "Only print files")
+}
+
+func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		return shared.Exitf("Usage: %s", c.Usage())
+	}
+	if c.filesOnly && c.dirsOnly {
+		return shared.Exitf("-d xor -f; can't give both")
+	}
+
+	var in io.Reader = os.Stdin
+	if name := f.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return shared.Exitf("Error opening recording: %v", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	list, err := fslist.New(fslist.Mode(c.mode), fslist.Options{})
+	if err != nil {
+		return shared.Exitf("Error creating index: %v", err)
+	}
+	defer list.Close()
+
+	if err := fscache.Replay(in, list, fscache.ReplayOptions{Raw: c.raw}); err != nil {
+		return shared.Exitf("Error replaying: %v", err)
+	}
+
+	names := []string{}
+	for data := range list.Fetch(fslist.ReadOptions{DirsOnly: c.dirsOnly, FilesOnly: c.filesOnly}) {
+		names = append(names, data.Name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+
+	return subcommands.ExitSuccess
+}
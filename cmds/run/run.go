@@ -6,14 +6,20 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/subcommands"
 	daemon "github.com/sevlyar/go-daemon"
 
 	"github.com/keyneston/fscache/fscache"
 	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/ignorer"
 	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/internal/walk"
+	"github.com/keyneston/fscache/watcher"
 )
 
 type Command struct {
@@ -21,7 +27,23 @@ type Command struct {
 
 	root      string
 	mode      string
+	watcher   string
 	daemonize bool
+
+	pollInterval   time.Duration
+	pollBudget     int
+	coalesceWindow time.Duration
+	followSymlinks bool
+	oneFileSystem  bool
+	skipFSTypes    string
+	record         string
+	ephemeral      bool
+
+	ignoreFiles    string
+	gitExclude     bool
+	excludesFile   string
+	noExcludesFile bool
+	userIgnoreFile string
 }
 
 func (*Command) Name() string     { return "run" }
@@ -37,6 +59,20 @@ func (c *Command) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.root, "r", "", "Root directory to monitor")
 	f.StringVar(&c.root, "root", "", "Alias for -r")
 	f.StringVar(&c.mode, "mode", "pebble", "DB mode; experimental")
+	f.StringVar(&c.watcher, "watcher", "default", "Watcher to use. Options: default, fanotify, poll")
+	f.DurationVar(&c.pollInterval, "poll-interval", watcher.DefaultPollInterval, "Time between scans with -watcher poll")
+	f.IntVar(&c.pollBudget, "poll-budget", 0, "Directories checked per scan with -watcher poll. 0 for all")
+	f.DurationVar(&c.coalesceWindow, "coalesce-window", watcher.DefaultCoalesceWindow, "How long to hold events so they can be coalesced")
+	f.BoolVar(&c.followSymlinks, "follow-symlinks", false, "Index and watch the directories symlinks point to")
+	f.BoolVar(&c.oneFileSystem, "one-file-system", false, "Don't descend into other filesystems mounted below the root")
+	f.StringVar(&c.skipFSTypes, "skip-fs-types", strings.Join(walk.DefaultSkipFSTypes, ","), "Comma separated filesystem types not to descend into")
+	f.StringVar(&c.ignoreFiles, "ignore-files", fslist.DefaultIgnoreFile, "Comma separated names of the ignore files read in every directory, later ones taking precedence, e.g. .gitignore,.ignore,.fdignore")
+	f.BoolVar(&c.gitExclude, "git-exclude", true, "Read .git/info/exclude in every git repository")
+	f.StringVar(&c.excludesFile, "excludes-file", "", "Global ignore file. Defaults to git's core.excludesFile")
+	f.BoolVar(&c.noExcludesFile, "no-excludes-file", false, "Don't read a global ignore file")
+	f.StringVar(&c.userIgnoreFile, "user-ignore-file", "", "File of paths never to index, changed with the ignore command. Defaults to ~/.config/fscache/ignore")
+	f.BoolVar(&c.ephemeral, "ephemeral", false, "Build a fresh index every run instead of keeping it in ~/.cache/fscache")
+	f.StringVar(&c.record, "record", "", "Append every batch of watcher events to this file, for use with replay")
 	f.BoolVar(&c.daemonize, "daemonize", false, "Launch as a daemon")
 }
 
@@ -55,6 +91,14 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		return shared.Exitf("Unable to get socket location: %v", err)
 	}
 
+	if c.record != "" {
+		// Resolved before daemonizing, in case that changes directory.
+		c.record, err = filepath.Abs(c.record)
+		if err != nil {
+			return shared.Exitf("Unable to get record location: %v", err)
+		}
+	}
+
 	if c.daemonize {
 		daemonCtx := daemon.Context{}
 		child, _ := daemonCtx.Reborn()
@@ -82,7 +126,57 @@ func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{})
 		}
 	}
 
-	fs, err := fscache.New(socketLoc, c.root, fslist.Mode(c.mode))
+	opts := fscache.Options{
+		Watcher: watcher.Options{
+			Kind: watcher.Kind(c.watcher),
+			Poll: watcher.PollOptions{
+				Interval: c.pollInterval,
+				Budget:   c.pollBudget,
+			},
+		},
+		CoalesceWindow: c.coalesceWindow,
+		FollowSymlinks: c.followSymlinks,
+		OneFileSystem:  c.oneFileSystem,
+		SkipFSTypes:    splitList(c.skipFSTypes),
+		Ignore: fslist.IgnoreSources{
+			Files:   splitList(c.ignoreFiles),
+			Exclude: c.gitExclude,
+		},
+	}
+
+	if !c.noExcludesFile {
+		opts.Ignore.ExcludesFile = c.excludesFile
+		if opts.Ignore.ExcludesFile == "" {
+			opts.Ignore.ExcludesFile = ignorer.ExcludesFile()
+		}
+	}
+
+	opts.UserIgnoreFile = c.userIgnoreFile
+	if opts.UserIgnoreFile == "" {
+		opts.UserIgnoreFile, err = ignorer.UserIgnoreFile()
+		if err != nil {
+			return shared.Exitf("Unable to get user ignore file location: %v", err)
+		}
+	}
+
+	// The memory mode has nothing to keep, so is always ephemeral.
+	if !c.ephemeral && c.mode != fslist.ModeMemory {
+		opts.Index, err = shared.IndexLocation(c.root, c.mode)
+		if err != nil {
+			return shared.Exitf("Unable to get index location: %v", err)
+		}
+	}
+
+	if c.record != "" {
+		record, err := os.OpenFile(c.record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return shared.Exitf("Error opening record file: %v", err)
+		}
+		defer record.Close()
+		opts.Record = record
+	}
+
+	fs, err := fscache.New(socketLoc, c.root, fslist.Mode(c.mode), opts)
 	if err != nil {
 		return shared.Exitf("Error starting monitor: %v", err)
 	}
@@ -105,3 +199,15 @@ func restart() error {
 	shared.Logger().Debug().Str("bin", bin).Strs("args", os.Args).Msg("restarting")
 	return syscall.Exec(bin, os.Args, os.Environ())
 }
+
+// splitList splits a comma separated flag, dropping empty entries.
+func splitList(list string) []string {
+	res := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/subcommands"
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type Command struct {
+	*shared.Config
+	logger zerolog.Logger
+}
+
+func (*Command) Name() string     { return "stats" }
+func (*Command) Synopsis() string { return "Show statistics from running fscache" }
+func (*Command) Usage() string {
+	return `stats:
+`
+}
+
+func (c *Command) SetFlags(f *flag.FlagSet) {
+	c.Config.SetFlags(f)
+}
+
+func (c *Command) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	c.logger = shared.Logger().With().Str("command", "stats").Logger()
+
+	client, err := c.Client()
+	if err != nil {
+		return shared.Exitf("Error connecting to fscache: %v", err)
+	}
+
+	stats, err := client.GetStats(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		return shared.Exitf("Error fetching stats: %v", err)
+	}
+
+	saved := 0.0
+	if stats.EventsReceived > 0 {
+		saved = 100 * float64(stats.EventsReceived-stats.EventsEmitted) / float64(stats.EventsReceived)
+	}
+
+	fmt.Printf("files indexed:   %d\n", stats.Files)
+	fmt.Printf("dirs indexed:    %d\n", stats.Dirs)
+	fmt.Printf("index seq:       %d\n", stats.Seq)
+	fmt.Printf("events received: %d\n", stats.EventsReceived)
+	fmt.Printf("events applied:  %d\n", stats.EventsEmitted)
+	fmt.Printf("saved by coalescing: %.1f%%\n", saved)
+
+	return subcommands.ExitSuccess
+}
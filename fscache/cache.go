@@ -2,6 +2,8 @@ package fscache
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
 	"os"
 	"os/signal"
@@ -13,6 +15,7 @@ import (
 	"github.com/keyneston/fscache/fslist"
 	"github.com/keyneston/fscache/ignorer"
 	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/internal/walk"
 	"github.com/keyneston/fscache/proto"
 	"github.com/keyneston/fscache/watcher"
 	"github.com/rs/zerolog"
@@ -22,6 +25,10 @@ import (
 
 var DefaultFlushTime = time.Second * 1
 
+// initBatchSize is how many entries the initial walk, or a rescan, changes per
+// batch.
+const initBatchSize = 10000
+
 var _ proto.FSCacheServer = &FSCache{}
 
 type FSCache struct {
@@ -30,53 +37,146 @@ type FSCache struct {
 	Root string
 
 	fileList fslist.FSList
-	watcher  watcher.Watcher
-	socket   net.Listener
-	server   *grpc.Server
-	ignore   ignorer.GlobalIgnore
+	// batch, if set, is where changes are written until it is committed.
+	batch          fslist.Batch
+	watcher        *watcher.Coalescer
+	socket         net.Listener
+	server         *grpc.Server
+	ignore         ignorer.GlobalIgnore
+	userIgnoreFile string
+	followSymlinks bool
+	boundary       *walk.Boundary
+	persistent     bool
 
 	ctx           context.Context
 	cancel        context.CancelFunc
 	closeOnce     *sync.Once
 	signalRestart bool
 	wg            *sync.WaitGroup
+	// calls are run by the main loop, between batches of events, see do.
+	calls chan func()
 
 	logger zerolog.Logger
 }
 
-func New(socketLocation, root string, mode fslist.Mode) (*FSCache, error) {
-	watcher, err := watcher.New(root)
+// Options holds the optional settings for an FSCache. The zero value uses the
+// defaults.
+type Options struct {
+	// Watcher selects and configures the watcher, see watcher.Open.
+	Watcher watcher.Options
+	// Source, if set, is used instead of opening a watcher. Tests use it to
+	// script events with a watcher.Fake.
+	Source watcher.Watcher
+	// Record, if set, gets every batch of events from the watcher, before
+	// they are coalesced. See watcher.Recorder and Replay.
+	Record io.Writer
+
+	// Index is where the index is kept between runs, see fslist.Open. It is
+	// reconciled with the disk on startup. If empty a fresh index is built
+	// every run and thrown away on close.
+	Index string
+
+	// CoalesceWindow is how long events are held so they can be coalesced
+	// before being applied. 0 only coalesces each batch from the watcher.
+	CoalesceWindow time.Duration
+
+	// FollowSymlinks indexes and watches the directories symlinks point to,
+	// as if they were below the link.
+	FollowSymlinks bool
+
+	// OneFileSystem stays on the filesystem root is on, without descending
+	// into anything mounted below it.
+	OneFileSystem bool
+	// SkipFSTypes are filesystem types, as named by walk.FSType, which aren't
+	// descended into. Their mount points are still indexed.
+	SkipFSTypes []string
+
+	// Ignore is where the rules for which entries reads leave out are read
+	// from, see fslist.IgnoreSources.
+	Ignore fslist.IgnoreSources
+	// UserIgnoreFile is where the user's own global ignores are kept, see
+	// ignorer.ReadUserIgnores. Entries they match are never indexed, like
+	// those in ignorer.GlobalIgnoreList. If empty there are none, and they
+	// can't be changed.
+	UserIgnoreFile string
+}
+
+func New(socketLocation, root string, mode fslist.Mode, opts Options) (*FSCache, error) {
+	// Entries are indexed by their absolute path, so the root has to match.
+	root, err := filepath.Abs(root)
 	if err != nil {
 		return nil, err
 	}
 
-	socket, err := net.Listen("unix", socketLocation)
+	boundary, err := walk.NewBoundary(root, opts.OneFileSystem, opts.SkipFSTypes)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	userIgnores := []string{}
+	if opts.UserIgnoreFile != "" {
+		userIgnores, err = ignorer.ReadUserIgnores(opts.UserIgnoreFile)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	fs := &FSCache{
-		Root:      root,
-		watcher:   watcher,
-		socket:    socket,
-		logger:    shared.Logger().With().Str("object", "fscache").Logger(),
-		server:    grpc.NewServer(),
-		cancel:    cancel,
-		ctx:       ctx,
-		closeOnce: &sync.Once{},
-		wg:        &sync.WaitGroup{},
-		ignore:    ignorer.NewGlobalIgnore(),
+	// The index is opened first, as it is the most likely to fail, and
+	// everything opened after it is closed again if anything else does.
+	var fileList fslist.FSList
+	if opts.Index != "" {
+		fileList, err = fslist.Open(mode, opts.Index, fslist.Options{Ignore: opts.Ignore})
+	} else {
+		fileList, err = fslist.New(mode, fslist.Options{Ignore: opts.Ignore})
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	proto.RegisterFSCacheServer(fs.server, fs)
+	w := opts.Source
+	if w == nil {
+		opts.Watcher.FollowSymlinks = opts.FollowSymlinks
+		opts.Watcher.Boundary = boundary
+		w, err = watcher.Open(root, opts.Watcher)
+		if err != nil {
+			fileList.Close()
+			return nil, err
+		}
+	}
+	if opts.Record != nil {
+		w = watcher.NewRecorder(w, opts.Record)
+	}
 
-	fs.fileList, err = fslist.New(mode)
+	socket, err := net.Listen("unix", socketLocation)
 	if err != nil {
+		w.Stop()
+		fileList.Close()
 		return nil, err
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fs := &FSCache{
+		Root:           root,
+		watcher:        watcher.NewCoalescer(w, opts.CoalesceWindow),
+		socket:         socket,
+		logger:         shared.Logger().With().Str("object", "fscache").Logger(),
+		server:         grpc.NewServer(),
+		cancel:         cancel,
+		ctx:            ctx,
+		closeOnce:      &sync.Once{},
+		wg:             &sync.WaitGroup{},
+		calls:          make(chan func()),
+		fileList:       fileList,
+		ignore:         ignorer.NewGlobalIgnore(userIgnores),
+		userIgnoreFile: opts.UserIgnoreFile,
+		followSymlinks: opts.FollowSymlinks,
+		boundary:       boundary,
+		persistent:     opts.Index != "",
+	}
+
+	proto.RegisterFSCacheServer(fs.server, fs)
+
 	return fs, nil
 }
 
@@ -95,9 +195,9 @@ func (fs *FSCache) Run() bool {
 	for {
 		select {
 		case events := <-fs.watcher.Stream():
-			for _, e := range events {
-				fs.handleEvent(e)
-			}
+			fs.apply(events)
+		case call := <-fs.calls:
+			call()
 		case <-fs.ctx.Done():
 			fs.logger.Warn().Err(fs.ctx.Err()).Msg("receive context.Done")
 			return fs.signalRestart
@@ -107,14 +207,29 @@ func (fs *FSCache) Run() bool {
 			}
 		}
 	}
-
-	return fs.signalRestart
 }
 
 func (fs *FSCache) Flush() error {
 	return fs.fileList.Flush()
 }
 
+// do runs f on the main loop, so it can change the index and what is ignored
+// without racing with the events being applied, and returns its error.
+func (fs *FSCache) do(ctx context.Context, f func() error) error {
+	done := make(chan error, 1)
+	call := func() { done <- f() }
+
+	select {
+	case fs.calls <- call:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-fs.ctx.Done():
+		return fs.ctx.Err()
+	}
+
+	return <-done
+}
+
 func (fs *FSCache) setSignalHandlers() {
 	ch := make(chan os.Signal, 1)
 
@@ -125,17 +240,82 @@ func (fs *FSCache) setSignalHandlers() {
 	}()
 }
 
+// apply handles events in a single batch, so readers see either none or all of
+// them. Rescans and syncs commit what came before them first.
+func (fs *FSCache) apply(events []watcher.Event) {
+	fs.batch = fs.fileList.NewBatch()
+	for _, e := range events {
+		fs.handleEvent(e)
+	}
+	fs.commit()
+}
+
+// index is where changes are written: the current batch if there is one,
+// otherwise straight to the fileList.
+func (fs *FSCache) index() fslist.Writer {
+	if fs.batch != nil {
+		return fs.batch
+	}
+	return fs.fileList
+}
+
+// commit commits the current batch, if there is one.
+func (fs *FSCache) commit() {
+	if fs.batch == nil {
+		return
+	}
+
+	if err := fs.batch.Commit(); err != nil {
+		fs.logger.Error().Err(err).Msgf("Error committing batch: %v", err)
+	}
+	fs.batch = nil
+}
+
+// flush commits the current batch, if there is one, and starts another in its
+// place, so what has been written so far can be read back.
+func (fs *FSCache) flush() {
+	if fs.batch == nil {
+		return
+	}
+
+	fs.commit()
+	fs.batch = fs.fileList.NewBatch()
+}
+
+// eventToAddData converts e, taking the modification time from disk. It is
+// left unset if the path can't be stat'd, as happens for deletes.
 func eventToAddData(e watcher.Event) fslist.AddData {
-	updatedAt := time.Now()
+	data := fslist.AddData{
+		Name:  e.Path,
+		IsDir: e.Dir,
+	}
+
+	if info, err := os.Lstat(e.Path); err == nil {
+		updatedAt := info.ModTime().UTC()
+		data.UpdatedAt = &updatedAt
 
-	return fslist.AddData{
-		Name:      e.Path,
-		IsDir:     e.Dir,
-		UpdatedAt: &updatedAt,
+		if info.Mode()&os.ModeSymlink != 0 {
+			data.Symlink = true
+			data.Target, _ = os.Readlink(e.Path)
+		}
 	}
+
+	return data
 }
 
 func (fs *FSCache) handleEvent(e watcher.Event) {
+	switch e.Type {
+	case watcher.EventTypeRename:
+		fs.handleRename(e)
+		return
+	case watcher.EventTypeSync:
+		// Events are applied in order, so everything before it is done once
+		// it has been committed.
+		fs.flush()
+		e.Ack()
+		return
+	}
+
 	if fs.ignore.Match(e.Path, e.Dir) {
 		fs.logger.Debug().Msgf("Skipping %#q", e.Path)
 		return
@@ -144,14 +324,59 @@ func (fs *FSCache) handleEvent(e watcher.Event) {
 	switch e.Type {
 	case watcher.EventTypeDelete:
 		fs.logger.Trace().Str("path", e.Path).Msg("removing")
-		if err := fs.fileList.Delete(eventToAddData(e)); err != nil {
+		if err := fs.index().Delete(eventToAddData(e)); err != nil {
 			fs.logger.Error().Str("path", e.Path).Err(err).Msgf("Error deleting file: %v", err)
 		}
 	case watcher.EventTypeAdd:
 		fs.logger.Trace().Str("path", e.Path).Msg("adding")
-		if err := fs.fileList.Add(eventToAddData(e)); err != nil {
+		if err := fs.index().Add(eventToAddData(e)); err != nil {
 			fs.logger.Error().Str("path", e.Path).Err(err).Msgf("Error adding file: %v", err)
 		}
+	case watcher.EventTypeModify:
+		// Add replaces the existing entry, updating its mtime.
+		fs.logger.Trace().Str("path", e.Path).Msg("modifying")
+		if err := fs.index().Add(eventToAddData(e)); err != nil {
+			fs.logger.Error().Str("path", e.Path).Err(err).Msgf("Error updating file: %v", err)
+		}
+	case watcher.EventTypeRescan:
+		fs.rescan(e.Path)
+	}
+}
+
+// handleRename moves the indexed entries from e.OldPath to e.Path. When one
+// side of the rename is ignored it is instead treated as an add or delete of
+// the other.
+func (fs *FSCache) handleRename(e watcher.Event) {
+	oldIgnored := fs.ignore.Match(e.OldPath, e.Dir)
+	newIgnored := fs.ignore.Match(e.Path, e.Dir)
+
+	switch {
+	case oldIgnored && newIgnored:
+		fs.logger.Debug().Msgf("Skipping %#q", e.Path)
+	case newIgnored:
+		fs.handleEvent(watcher.Event{Path: e.OldPath, Type: watcher.EventTypeDelete, Dir: e.Dir})
+	case oldIgnored:
+		fs.addRenamed(e)
+	default:
+		fs.logger.Trace().Str("from", e.OldPath).Str("to", e.Path).Msg("moving")
+		from := fslist.AddData{Name: e.OldPath, IsDir: e.Dir}
+		err := fs.index().Move(from, eventToAddData(e))
+		switch {
+		case errors.Is(err, fslist.ErrNotIndexed):
+			fs.addRenamed(e)
+		case err != nil:
+			fs.logger.Error().Str("from", e.OldPath).Str("to", e.Path).Err(err).Msgf("Error moving file: %v", err)
+		}
+	}
+}
+
+// addRenamed adds the new path of a rename whose old path wasn't indexed.
+// Nothing below it was indexed either, so the whole subtree has to be picked
+// up from disk.
+func (fs *FSCache) addRenamed(e watcher.Event) {
+	fs.handleEvent(watcher.Event{Path: e.Path, Type: watcher.EventTypeAdd, Dir: e.Dir})
+	if e.Dir {
+		fs.rescan(e.Path)
 	}
 }
 
@@ -169,70 +394,226 @@ func (fs *FSCache) Close() {
 	})
 }
 
-// init does the initial setup of walking
+// init does the initial setup of walking. A persistent index is instead
+// reconciled with the disk, to pick up whatever changed while fscache wasn't
+// running.
 func (fs *FSCache) init() {
+	fs.batch = fs.fileList.NewBatch()
+	defer fs.commit()
+
+	// An empty index has nothing to reconcile, so is filled in the same way
+	// as an ephemeral one.
+	if fs.persistent && fs.fileList.Len() > 0 {
+		fs.rescan(fs.Root)
+		return
+	}
+
 	fs.wg.Add(1)
 	defer fs.wg.Done()
 
-	if entry, err := getDirEntry(fs.Root); err != nil {
-		fs.logger.Error().Err(err).Str("root", fs.Root).Msg("error getting entry for root")
-	} else {
-		// first "walk" the root directory itself
-		if err := fs.walkFunc(fs.Root, entry, nil); err != nil {
-			fs.logger.Error().Err(err).Msg("error walking root")
+	// The walk is committed every initBatchSize entries, so readers see the
+	// index fill in without it being written an entry at a time.
+	added := 0
+	fs.walk(fs.Root, func(data fslist.AddData) error {
+		if added++; added%initBatchSize == 0 {
+			fs.flush()
 		}
-	}
-
-	filepath.WalkDir(fs.Root, fs.walkFunc)
+		return fs.index().Add(data)
+	})
 }
 
-func (fs *FSCache) walkFunc(path string, d os.DirEntry, err error) error {
-	select {
-	case <-fs.ctx.Done():
-		return fs.ctx.Err()
-	default:
+// rescan brings the index for the subtree at root back in line with what is
+// on disk. It is used when the watcher has lost events below root, so the
+// index can no longer be trusted there.
+func (fs *FSCache) rescan(root string) {
+	fs.wg.Add(1)
+	defer fs.wg.Done()
+
+	logger := fs.logger.With().Str("root", root).Logger()
+	logger.Info().Msg("rescanning")
+
+	prefix := root
+	if prefix[len(prefix)-1] != '/' {
+		prefix += "/"
 	}
 
-	isDir := false
-	updatedAt := time.Time{}
-	if d != nil {
-		isDir = d.IsDir()
+	// The index is read as committed, so anything pending is committed first.
+	fs.flush()
 
-		if info, err := d.Info(); err == nil {
-			updatedAt = info.ModTime().UTC()
+	// Unless root is a directory its own entry isn't under prefix, but it
+	// sorts before anything else starting with its name.
+	indexed := map[string]fslist.AddData{}
+	for data := range fs.fileList.Fetch(fslist.ReadOptions{Prefix: root, NoIgnore: true, Limit: 1}) {
+		if data.Name == root {
+			indexed[data.Name] = data
 		}
 	}
+	for data := range fs.fileList.Fetch(fslist.ReadOptions{Prefix: prefix, NoIgnore: true}) {
+		indexed[data.Name] = data
+	}
 
-	if fs.ignore.Match(path, isDir) {
-		fs.logger.Debug().Str("path", path).Msgf("Skipping %q", path)
-		if d.IsDir() {
-			return filepath.SkipDir
-		} else {
-			return nil
+	// As with the initial walk, the changes are committed every
+	// initBatchSize. indexed was read beforehand, so isn't affected.
+	changes := 0
+	changed := func() {
+		if changes++; changes%initBatchSize == 0 {
+			fs.flush()
 		}
 	}
 
-	abs, err := filepath.Abs(path)
-	if err != nil {
-		return err
+	seen := map[string]bool{}
+	added, updated, deleted := 0, 0, 0
+	if _, err := os.Lstat(root); err == nil {
+		fs.walk(root, func(data fslist.AddData) error {
+			if seen[data.Name] {
+				return nil
+			}
+			seen[data.Name] = true
+
+			old, ok := indexed[data.Name]
+			if !ok {
+				added++
+				changed()
+				return fs.index().Add(data)
+			}
+			if sameEntry(old, data) {
+				return nil
+			}
+
+			updated++
+			changed()
+			if old.IsDir != data.IsDir {
+				// They are stored under different keys, so the old entry
+				// wouldn't be replaced.
+				if err := fs.index().Delete(old); err != nil {
+					return err
+				}
+			}
+			return fs.index().Add(data)
+		})
 	}
 
-	return fs.fileList.Add(fslist.AddData{
-		Name:      abs,
-		UpdatedAt: &updatedAt,
-		IsDir:     isDir,
-	})
+	for name, data := range indexed {
+		if seen[name] {
+			continue
+		}
+
+		deleted++
+		changed()
+		if err := fs.index().Delete(data); err != nil {
+			logger.Error().Str("path", name).Err(err).Msgf("Error deleting file: %v", err)
+		}
+	}
+
+	logger.Info().Int("added", added).Int("updated", updated).Int("deleted", deleted).Msg("finished rescan")
+}
+
+// sameEntry reports whether the indexed entry a is still up to date with b,
+// as read from disk.
+func sameEntry(a, b fslist.AddData) bool {
+	if a.IsDir != b.IsDir || a.Symlink != b.Symlink || a.Target != b.Target {
+		return false
+	}
+	if a.UpdatedAt == nil || b.UpdatedAt == nil {
+		return a.UpdatedAt == b.UpdatedAt
+	}
+
+	return a.UpdatedAt.Equal(*b.UpdatedAt)
+}
+
+// walk hands root, and everything below it that isn't globally ignored, to
+// visit.
+func (fs *FSCache) walk(root string, visit func(fslist.AddData) error) {
+	fs.walkWith(fs.ignore, root, visit)
+}
+
+// walkWith is walk, leaving out what ignore matches instead of fs.ignore. It
+// doesn't read anything only the main loop changes, so can walk off it.
+func (fs *FSCache) walkWith(ignore ignorer.GlobalIgnore, root string, visit func(fslist.AddData) error) {
+	if err := walk.Walk(root, fs.followSymlinks, fs.walkFunc(ignore, visit)); err != nil {
+		fs.logger.Error().Err(err).Str("root", root).Msg("error walking")
+	}
+}
+
+func (fs *FSCache) walkFunc(ignore ignorer.GlobalIgnore, visit func(fslist.AddData) error) func(string, os.DirEntry, error) error {
+	return func(path string, d os.DirEntry, err error) error {
+		select {
+		case <-fs.ctx.Done():
+			return fs.ctx.Err()
+		default:
+		}
+
+		if errors.Is(err, walk.ErrSymlinkLoop) {
+			// The link itself has already been visited.
+			fs.logger.Debug().Str("path", path).Msg("symlink loop, not following")
+			return nil
+		}
+
+		isDir := false
+		symlink := false
+		updatedAt := time.Time{}
+		if d != nil {
+			isDir = d.IsDir()
+			symlink = d.Type()&os.ModeSymlink != 0
+
+			if info, err := d.Info(); err == nil {
+				updatedAt = info.ModTime().UTC()
+			}
+		}
+
+		if ignore.Match(path, isDir) {
+			fs.logger.Debug().Str("path", path).Msgf("Skipping %q", path)
+			if isDir {
+				return filepath.SkipDir
+			} else {
+				return nil
+			}
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		data := fslist.AddData{
+			Name:      abs,
+			UpdatedAt: &updatedAt,
+			IsDir:     isDir,
+			Symlink:   symlink,
+		}
+		if symlink {
+			data.Target, _ = os.Readlink(path)
+		}
+
+		if err := visit(data); err != nil {
+			return err
+		}
+
+		if fs.boundary.Excluded(path, d) {
+			fs.logger.Debug().Str("path", path).Msg("filesystem excluded, skipping contents")
+			return filepath.SkipDir
+		}
+		return nil
+	}
 }
 
 func (fs *FSCache) GetFiles(req *proto.ListRequest, srv proto.FSCache_GetFilesServer) error {
+	fs.wg.Add(1)
+	defer fs.wg.Done()
+
 	fs.logger.Debug().Interface("req", req).Msg("Received request")
 
 	opts := fslist.ReadOptions{
-		DirsOnly:   req.DirsOnly,
-		FilesOnly:  req.FilesOnly,
-		Prefix:     req.Prefix,
-		Limit:      int(req.Limit),
-		CurrentDir: req.CurrentDir,
+		DirsOnly:       req.DirsOnly,
+		FilesOnly:      req.FilesOnly,
+		Prefix:         req.Prefix,
+		Limit:          int(req.Limit),
+		CurrentDir:     req.CurrentDir,
+		Query:          req.Query,
+		NoIgnore:       req.NoIgnore,
+		NoGlobalIgnore: req.NoGlobalIgnore,
+		NoHidden:       req.NoHidden,
+		Root:           fs.Root,
 	}
 
 	batchSize := 10
@@ -240,25 +621,36 @@ func (fs *FSCache) GetFiles(req *proto.ListRequest, srv proto.FSCache_GetFilesSe
 		batchSize = int(req.BatchSize)
 	}
 
-	files := &proto.Files{}
-	for file := range fs.fileList.Fetch(opts) {
-		f := &proto.File{
-			Name: file.Name,
-			Dir:  file.IsDir,
+	// The whole response comes from one snapshot, so it is consistent
+	// however the index changes while it is being sent.
+	snapshot, err := fs.fileList.Snapshot()
+	if err != nil {
+		return err
+	}
+	ch := snapshot.Fetch(opts)
+	defer func() {
+		// The fetch has to finish before the snapshot can be closed.
+		for range ch {
 		}
+		snapshot.Close()
+	}()
 
-		files.Files = append(files.Files, f)
+	files := &proto.Files{Seq: snapshot.Seq()}
+	sent := false
+	for file := range ch {
+		files.Files = append(files.Files, file.ToProtoFile())
 
 		if len(files.Files) >= batchSize {
 			if err := srv.Send(files); err != nil {
 				return err
 			}
-			files = &proto.Files{}
+			files = &proto.Files{Seq: snapshot.Seq()}
+			sent = true
 		}
 	}
 
-	// Send any remaining data:
-	if len(files.Files) > 0 {
+	// Send any remaining data, or the seq alone if there was nothing:
+	if len(files.Files) > 0 || !sent {
 		if err := srv.Send(files); err != nil {
 			return err
 		}
@@ -267,6 +659,48 @@ func (fs *FSCache) GetFiles(req *proto.ListRequest, srv proto.FSCache_GetFilesSe
 	return nil
 }
 
+func (fs *FSCache) GetStats(ctx context.Context, _ *emptypb.Empty) (*proto.Stats, error) {
+	stats := fs.watcher.Stats()
+	totals := fs.fileList.Totals()
+
+	return &proto.Stats{
+		EventsReceived: stats.Received,
+		EventsEmitted:  stats.Emitted,
+		Files:          uint64(totals.Files),
+		Dirs:           uint64(totals.Dirs),
+		Seq:            fs.fileList.Seq(),
+	}, nil
+}
+
+func (fs *FSCache) Count(ctx context.Context, req *proto.CountRequest) (*proto.Counts, error) {
+	fs.logger.Debug().Interface("req", req).Msg("Received count request")
+
+	snapshot, err := fs.fileList.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+
+	counts, err := snapshot.Count(fslist.ReadOptions{
+		DirsOnly:       req.DirsOnly,
+		FilesOnly:      req.FilesOnly,
+		Prefix:         req.Prefix,
+		NoIgnore:       req.NoIgnore,
+		NoGlobalIgnore: req.NoGlobalIgnore,
+		NoHidden:       req.NoHidden,
+		Root:           fs.Root,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.Counts{
+		Files: uint64(counts.Files),
+		Dirs:  uint64(counts.Dirs),
+		Seq:   snapshot.Seq(),
+	}, nil
+}
+
 func (fs *FSCache) Shutdown(ctx context.Context, req *proto.ShutdownRequest) (*emptypb.Empty, error) {
 	fs.wg.Add(1)
 	defer fs.wg.Done()
@@ -0,0 +1,318 @@
+package fscache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCache builds an FSCache around root without a watcher or socket, so
+// events can be fed straight to handleEvent.
+func newTestCache(t *testing.T, root string) *FSCache {
+	list, err := fslist.New(fslist.ModeMemory, fslist.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { list.Close() })
+
+	return newTestCacheWith(t, root, list)
+}
+
+// newTestCacheWith is newTestCache using list, which the caller has to close.
+func newTestCacheWith(t *testing.T, root string, list fslist.FSList) *FSCache {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return &FSCache{
+		Root:      root,
+		fileList:  list,
+		ignore:    ignorer.NewGlobalIgnore(nil),
+		ctx:       ctx,
+		cancel:    cancel,
+		closeOnce: &sync.Once{},
+		wg:        &sync.WaitGroup{},
+		logger:    *shared.Logger(),
+	}
+}
+
+func listNames(fs *FSCache, opts fslist.ReadOptions) []string {
+	names := []string{}
+	for data := range fs.fileList.Fetch(opts) {
+		names = append(names, data.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestNewCleansUp(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "new-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	root := filepath.Join(tmp, "root")
+	require.NoError(t, os.Mkdir(root, 0755))
+
+	// The index can't be opened below a file, and nothing should be left
+	// behind when it fails.
+	socket := filepath.Join(tmp, "socket")
+	blocker := filepath.Join(tmp, "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0644))
+
+	fake := watcher.NewFake()
+	_, err = New(socket, root, fslist.ModePebble, Options{Source: fake, Index: filepath.Join(blocker, "index")})
+	require.Error(t, err)
+
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err), "socket left behind: %v", err)
+
+	// Nor when the socket can't be created, which happens after the
+	// watcher is opened.
+	_, err = New(filepath.Join(blocker, "socket"), root, fslist.ModeMemory, Options{Source: fake})
+	require.Error(t, err)
+
+	stopped := make(chan bool)
+	go func() {
+		fake.Sync()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("watcher left running")
+	}
+}
+
+func TestRescan(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "rescan-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	sub := filepath.Join(tmp, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "kept.txt"), nil, 0644))
+
+	fs := newTestCache(t, tmp)
+	fs.init()
+
+	// Change the disk behind the cache's back, as if the events were lost.
+	require.NoError(t, os.Remove(filepath.Join(sub, "kept.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "new.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "outside.txt"), nil, 0644))
+
+	fs.handleEvent(watcher.Event{Path: sub, Type: watcher.EventTypeRescan, Dir: true})
+
+	assert.Equal(t, []string{
+		tmp,
+		sub,
+		filepath.Join(sub, "new.txt"),
+	}, listNames(fs, fslist.ReadOptions{}))
+}
+
+func TestRescanRemovedRoot(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "rescan-removed-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	sub := filepath.Join(tmp, "sub")
+	link := filepath.Join(tmp, "link")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "file.txt"), nil, 0644))
+	require.NoError(t, os.Symlink(sub, link))
+
+	fs := newTestCache(t, tmp)
+	fs.init()
+	require.Len(t, listNames(fs, fslist.ReadOptions{}), 4)
+
+	// The root of a rescan goes along with everything below it, whether it
+	// was a directory or not.
+	require.NoError(t, os.RemoveAll(sub))
+	require.NoError(t, os.Remove(link))
+	fs.rescan(sub)
+	fs.rescan(link)
+
+	assert.Equal(t, []string{tmp}, listNames(fs, fslist.ReadOptions{}))
+}
+
+func TestRenameUnindexed(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "rename-unindexed-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	fs := newTestCache(t, tmp)
+	fs.init()
+
+	// The events for staging were lost, so the rename is all that is known
+	// of it, and what came along with it has to be found on disk.
+	staging := filepath.Join(tmp, "staging")
+	dist := filepath.Join(tmp, "dist")
+	require.NoError(t, os.MkdirAll(filepath.Join(staging, "js"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(staging, "js", "app.js"), nil, 0644))
+	require.NoError(t, os.Rename(staging, dist))
+
+	fs.apply([]watcher.Event{{Path: dist, OldPath: staging, Type: watcher.EventTypeRename, Dir: true}})
+
+	assert.Equal(t, []string{
+		tmp,
+		dist,
+		filepath.Join(dist, "js"),
+		filepath.Join(dist, "js", "app.js"),
+	}, listNames(fs, fslist.ReadOptions{}))
+}
+
+func TestInitSymlinks(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "symlinks-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	root := filepath.Join(tmp, "root")
+	data := filepath.Join(tmp, "data")
+	require.NoError(t, os.Mkdir(root, 0755))
+	require.NoError(t, os.Mkdir(data, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "main.go"), nil, 0644))
+	require.NoError(t, os.Symlink(data, filepath.Join(root, "src")))
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "loop")))
+
+	fs := newTestCache(t, root)
+	fs.init()
+
+	assert.Equal(t, []string{
+		root,
+		filepath.Join(root, "loop"),
+		filepath.Join(root, "src"),
+	}, listNames(fs, fslist.ReadOptions{}))
+
+	fs = newTestCache(t, root)
+	fs.followSymlinks = true
+	fs.init()
+
+	assert.Equal(t, []string{
+		root,
+		filepath.Join(root, "loop"),
+		filepath.Join(root, "src"),
+		filepath.Join(root, "src", "main.go"),
+	}, listNames(fs, fslist.ReadOptions{}))
+
+	links := map[string]fslist.AddData{}
+	for entry := range fs.fileList.Fetch(fslist.ReadOptions{}) {
+		if entry.Symlink {
+			links[entry.Name] = entry
+		}
+	}
+
+	require.Len(t, links, 2)
+	src := links[filepath.Join(root, "src")]
+	assert.Equal(t, data, src.Target)
+	assert.True(t, src.IsDir)
+	assert.False(t, links[filepath.Join(root, "loop")].IsDir)
+}
+
+func TestReconcile(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "reconcile-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	root := filepath.Join(tmp, "root")
+	index := filepath.Join(tmp, "index")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	for _, name := range []string{"kept.txt", "removed.txt", "changed.txt", "sub/file.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), nil, 0644))
+	}
+
+	open := func() *FSCache {
+		list, err := fslist.Open(fslist.ModePebble, index, fslist.Options{})
+		require.NoError(t, err)
+
+		fs := newTestCacheWith(t, root, list)
+		fs.persistent = true
+		return fs
+	}
+
+	fs := open()
+	fs.init()
+	require.NoError(t, fs.fileList.Close())
+
+	// Change things while fscache isn't running.
+	modified := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Remove(filepath.Join(root, "removed.txt")))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "changed.txt"), modified, modified))
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "sub")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "new.txt"), nil, 0644))
+
+	fs = open()
+	defer fs.fileList.Close()
+	fs.init()
+
+	assert.Equal(t, []string{
+		root,
+		filepath.Join(root, "changed.txt"),
+		filepath.Join(root, "kept.txt"),
+		filepath.Join(root, "new.txt"),
+		filepath.Join(root, "sub"),
+	}, listNames(fs, fslist.ReadOptions{}))
+
+	changed := 0
+	for data := range fs.fileList.Fetch(fslist.ReadOptions{Prefix: filepath.Join(root, "changed.txt")}) {
+		changed++
+		require.NotNil(t, data.UpdatedAt)
+		assert.Equal(t, modified, *data.UpdatedAt)
+	}
+	assert.Equal(t, 1, changed)
+
+	// sub went from a directory to a file.
+	assert.Equal(t, []string{root}, listNames(fs, fslist.ReadOptions{DirsOnly: true}))
+}
+
+func TestIgnoreFileEvents(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "ignore-events-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	gitignore := filepath.Join(tmp, ".gitignore")
+	require.NoError(t, os.WriteFile(gitignore, []byte("*.log\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "debug.log"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "main.tmp"), nil, 0644))
+
+	fs := newTestCache(t, tmp)
+	fs.init()
+
+	assert.Equal(t, []string{tmp, gitignore, filepath.Join(tmp, "main.tmp")}, listNames(fs, fslist.ReadOptions{}))
+
+	require.NoError(t, os.WriteFile(gitignore, []byte("*.tmp\n"), 0644))
+	fs.handleEvent(watcher.Event{Path: gitignore, Type: watcher.EventTypeModify})
+
+	assert.Equal(t, []string{tmp, gitignore, filepath.Join(tmp, "debug.log")}, listNames(fs, fslist.ReadOptions{}))
+
+	require.NoError(t, os.Remove(gitignore))
+	fs.handleEvent(watcher.Event{Path: gitignore, Type: watcher.EventTypeDelete})
+
+	assert.Equal(t, []string{tmp, filepath.Join(tmp, "debug.log"), filepath.Join(tmp, "main.tmp")}, listNames(fs, fslist.ReadOptions{}))
+}
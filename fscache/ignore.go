@@ -0,0 +1,300 @@
+package fscache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/keyneston/fscache/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var errNoUserIgnoreFile = errors.New("fscache is running without a user ignore file")
+
+// AddIgnores adds patterns to the user ignore file, and removes everything
+// they match from the index.
+func (fs *FSCache) AddIgnores(ctx context.Context, req *proto.IgnoreRequest) (*proto.Ignores, error) {
+	fs.logger.Debug().Interface("req", req).Msg("Received add ignores request")
+
+	return fs.changeIgnores(ctx, req.Patterns, true)
+}
+
+// RemoveIgnores removes patterns from the user ignore file. Whatever they kept
+// out of the index is picked up from disk in the background, see letIn.
+func (fs *FSCache) RemoveIgnores(ctx context.Context, req *proto.IgnoreRequest) (*proto.Ignores, error) {
+	fs.logger.Debug().Interface("req", req).Msg("Received remove ignores request")
+
+	return fs.changeIgnores(ctx, req.Patterns, false)
+}
+
+func (fs *FSCache) ListIgnores(ctx context.Context, _ *emptypb.Empty) (*proto.Ignores, error) {
+	res := &proto.Ignores{}
+	if err := fs.listIgnores(res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// changeIgnores adds or removes patterns from the user ignore file, then brings
+// the index in line with it.
+func (fs *FSCache) changeIgnores(ctx context.Context, patterns []string, add bool) (*proto.Ignores, error) {
+	if fs.userIgnoreFile == "" {
+		return nil, errNoUserIgnoreFile
+	}
+	for _, p := range patterns {
+		if err := ignorer.ValidatePattern(p); err != nil {
+			return nil, err
+		}
+	}
+
+	change := ignorer.RemoveUserIgnores
+	if add {
+		change = ignorer.AddUserIgnores
+	}
+
+	res := &proto.Ignores{}
+	err := fs.do(ctx, func() error {
+		changed, err := change(fs.userIgnoreFile, patterns)
+		if err != nil {
+			return err
+		}
+		res.Changed = changed
+
+		if len(changed) == 0 {
+			return fs.listIgnores(res)
+		}
+
+		user, err := ignorer.ReadUserIgnores(fs.userIgnoreFile)
+		if err != nil {
+			return err
+		}
+		old := fs.ignore
+		fs.ignore = ignorer.NewGlobalIgnore(user)
+
+		// Removing a negated pattern ignores more, so either way there may
+		// be something to purge.
+		purged, err := fs.purgeIgnored()
+		res.Purged = uint64(purged)
+		if err != nil {
+			return fmt.Errorf("the ignore file was changed, but not everything it ignores could be removed from the index: %w", err)
+		}
+
+		// Nothing was indexed below what was ignored, so whatever is let
+		// back in has to be picked up from disk.
+		if roots := letInRoots(fs.Root, changed, add); len(roots) > 0 {
+			fs.letIn(roots, old, fs.ignore)
+		}
+
+		return fs.listIgnores(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// letInRoots returns the directories in root below which adding, or removing,
+// patterns can stop something from being ignored: adding a negated pattern or
+// removing any other.
+func letInRoots(root string, patterns []string, add bool) []string {
+	roots := []string{}
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") != add {
+			continue
+		}
+
+		switch dir := patternDir(strings.TrimPrefix(p, "!")); {
+		case within(dir, root):
+			roots = append(roots, dir)
+		case within(root, dir):
+			roots = append(roots, root)
+		}
+	}
+
+	// Those inside another are walked along with it.
+	sort.Strings(roots)
+	outer := []string{}
+	for _, dir := range roots {
+		if len(outer) == 0 || !within(dir, outer[len(outer)-1]) {
+			outer = append(outer, dir)
+		}
+	}
+
+	return outer
+}
+
+// patternDir returns the directory everything pattern matches is in. The user's
+// patterns are relative to "/", and only anchored to it by a '/' other than a
+// trailing one, so the rest can match anywhere.
+func patternDir(pattern string) string {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		return "/"
+	}
+
+	dir := "/"
+	for _, segment := range strings.Split(strings.TrimPrefix(pattern, "/"), "/") {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		dir = filepath.Join(dir, segment)
+	}
+
+	return dir
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// letIn indexes what old ignored below roots, and ignore no longer does. The
+// walk is done in the background, so the main loop isn't held up while it
+// reads the disk, and what it finds is added on the main loop initBatchSize
+// entries at a time.
+func (fs *FSCache) letIn(roots []string, old, ignore ignorer.GlobalIgnore) {
+	fs.wg.Add(1)
+	go func() {
+		defer fs.wg.Done()
+
+		found := []fslist.AddData{}
+		add := func() error {
+			batch := found
+			found = []fslist.AddData{}
+			return fs.do(fs.ctx, func() error { return fs.addLetIn(batch) })
+		}
+
+		for _, root := range roots {
+			if _, err := os.Lstat(root); err != nil {
+				// An anchored pattern may be of something which isn't
+				// there.
+				continue
+			}
+
+			fs.logger.Info().Str("root", root).Msg("indexing what is no longer ignored")
+			fs.walkWith(ignore, root, func(data fslist.AddData) error {
+				if !old.Match(data.Name, data.IsDir) {
+					// Already indexed.
+					return nil
+				}
+
+				if found = append(found, data); len(found) >= initBatchSize {
+					return add()
+				}
+				return nil
+			})
+		}
+
+		if len(found) == 0 {
+			return
+		}
+		if err := add(); err != nil {
+			fs.logger.Error().Err(err).Msg("error indexing what is no longer ignored")
+		}
+	}()
+}
+
+// addLetIn adds the entries letIn found, leaving out any which have since
+// been deleted or are ignored again. They are read from disk again, as they
+// may have changed since they were found.
+func (fs *FSCache) addLetIn(found []fslist.AddData) error {
+	batch := fs.fileList.NewBatch()
+	defer batch.Close()
+
+	for _, data := range found {
+		info, err := os.Lstat(data.Name)
+		if err != nil || fs.ignore.Match(data.Name, data.IsDir) {
+			continue
+		}
+
+		updatedAt := info.ModTime().UTC()
+		data.UpdatedAt = &updatedAt
+		if err := batch.Add(data); err != nil {
+			return err
+		}
+	}
+
+	return batch.Commit()
+}
+
+// listIgnores fills in the built in and user patterns of res.
+func (fs *FSCache) listIgnores(res *proto.Ignores) error {
+	scanner := bufio.NewScanner(ignorer.GlobalIgnoreList())
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			res.Builtin = append(res.Builtin, line)
+		}
+	}
+
+	if fs.userIgnoreFile == "" {
+		return nil
+	}
+
+	var err error
+	res.Patterns, err = ignorer.ReadUserIgnores(fs.userIgnoreFile)
+	return err
+}
+
+// purgeIgnored removes everything from the index which is now globally
+// ignored, returning how many entries went. It carries on past entries which
+// can't be deleted, returning the first error.
+func (fs *FSCache) purgeIgnored() (int, error) {
+	fs.wg.Add(1)
+	defer fs.wg.Done()
+
+	// As with rescan, the index is read in full before anything is removed.
+	ignored := []fslist.AddData{}
+	for data := range fs.fileList.Fetch(fslist.ReadOptions{NoIgnore: true}) {
+		if fs.ignore.Match(data.Name, data.IsDir) {
+			ignored = append(ignored, data)
+		}
+	}
+
+	// Everything below an ignored directory is ignored too, and goes along
+	// with it. Sorting puts directories before what they hold.
+	sort.Sort(fslist.ByPath(ignored))
+
+	batch := fs.fileList.NewBatch()
+	defer batch.Close()
+
+	var firstErr error
+	purged := 0
+	removed := map[string]bool{}
+	for _, data := range ignored {
+		if removed[filepath.Dir(data.Name)] {
+			if data.IsDir {
+				removed[data.Name] = true
+			}
+			purged++
+			continue
+		}
+
+		if err := batch.Delete(data); err != nil {
+			fs.logger.Error().Str("path", data.Name).Err(err).Msgf("Error deleting file: %v", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", data.Name, err)
+			}
+			continue
+		}
+		if data.IsDir {
+			removed[data.Name] = true
+		}
+		purged++
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, err
+	}
+
+	fs.logger.Info().Int("purged", purged).Msg("purged ignored entries")
+	return purged, firstErr
+}
@@ -0,0 +1,95 @@
+package fscache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingList fails to delete the entries named in fail.
+type failingList struct {
+	fslist.FSList
+	fail map[string]bool
+}
+
+func (l failingList) NewBatch() fslist.Batch {
+	return failingBatch{Batch: l.FSList.NewBatch(), fail: l.fail}
+}
+
+type failingBatch struct {
+	fslist.Batch
+	fail map[string]bool
+}
+
+func (b failingBatch) Delete(data fslist.AddData) error {
+	if b.fail[data.Name] {
+		return errors.New("delete failed")
+	}
+	return b.Batch.Delete(data)
+}
+
+func TestPurgeIgnored(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "purge-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	for _, name := range []string{"dist/bundle.js", "out/bin", "main.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmp, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tmp, name), nil, 0644))
+	}
+
+	list, err := fslist.New(fslist.ModeMemory, fslist.Options{})
+	require.NoError(t, err)
+	defer list.Close()
+
+	fs := newTestCacheWith(t, tmp, failingList{FSList: list, fail: map[string]bool{filepath.Join(tmp, "out"): true}})
+	fs.init()
+	require.Len(t, listNames(fs, fslist.ReadOptions{}), 6)
+
+	// out couldn't be deleted, so isn't counted, but what is in it still
+	// goes on its own.
+	fs.ignore = ignorer.NewGlobalIgnore([]string{"dist/", "out/"})
+	purged, err := fs.purgeIgnored()
+	assert.Error(t, err)
+	assert.Equal(t, 3, purged)
+
+	assert.Equal(t, []string{
+		tmp,
+		filepath.Join(tmp, "main.go"),
+		filepath.Join(tmp, "out"),
+	}, listNames(fs, fslist.ReadOptions{}))
+}
+
+func TestLetInRoots(t *testing.T) {
+	type testCase struct {
+		patterns []string
+		add      bool
+		expected []string
+	}
+
+	testCases := []testCase{
+		// Adding an ordinary pattern only ever ignores more.
+		{patterns: []string{"dist/", "/home/u/src"}, add: true, expected: []string{}},
+		{patterns: []string{"dist/"}, expected: []string{"/home/u"}},
+		{patterns: []string{"**/build"}, expected: []string{"/home/u"}},
+		{patterns: []string{"!/home/u/src/*.log"}, add: true, expected: []string{"/home/u/src"}},
+		{patterns: []string{"/home/u/src/a", "/home/u/src/b/", "/home/u/src"}, expected: []string{"/home/u/src"}},
+		{patterns: []string{"/home/u/src/a", "/home/u/tmp/[ab]*"}, expected: []string{"/home/u/src/a", "/home/u/tmp"}},
+		// Patterns above the root let in all of it, those outside it nothing.
+		{patterns: []string{"/home"}, expected: []string{"/home/u"}},
+		{patterns: []string{"/opt/big", "/home/user"}, expected: []string{}},
+	}
+
+	for _, c := range testCases {
+		assert.Equal(t, c.expected, letInRoots("/home/u", c.patterns, c.add), "letInRoots(%q, %v)", c.patterns, c.add)
+	}
+}
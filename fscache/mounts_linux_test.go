@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package fscache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/internal/walk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestInitOneFileSystem(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "boundary-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	mnt := filepath.Join(tmp, "mnt")
+	require.NoError(t, os.Mkdir(mnt, 0755))
+	if err := unix.Mount("tmpfs", mnt, "tmpfs", 0, ""); err != nil {
+		t.Skipf("unable to mount tmpfs: %v", err)
+	}
+	defer unix.Unmount(mnt, unix.MNT_DETACH)
+
+	require.NoError(t, os.WriteFile(filepath.Join(mnt, "mounted.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "local.txt"), nil, 0644))
+
+	fs := newTestCache(t, tmp)
+	fs.boundary, err = walk.NewBoundary(tmp, true, nil)
+	require.NoError(t, err)
+	fs.init()
+
+	// The mount point is kept, but not what is below it.
+	assert.Equal(t, []string{
+		tmp,
+		filepath.Join(tmp, "local.txt"),
+		mnt,
+	}, listNames(fs, fslist.ReadOptions{}))
+
+	// Unmounting rescans the mount point, which has what was underneath.
+	require.NoError(t, unix.Unmount(mnt, 0))
+	require.NoError(t, os.WriteFile(filepath.Join(mnt, "under.txt"), nil, 0644))
+	fs.rescan(mnt)
+
+	assert.Equal(t, []string{
+		tmp,
+		filepath.Join(tmp, "local.txt"),
+		mnt,
+		filepath.Join(mnt, "under.txt"),
+	}, listNames(fs, fslist.ReadOptions{}))
+}
@@ -0,0 +1,50 @@
+package fscache
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/watcher"
+)
+
+// ReplayOptions holds the optional settings for Replay.
+type ReplayOptions struct {
+	// Raw applies the events exactly as recorded. Otherwise each batch is
+	// coalesced first, as a running cache with a coalesce window of 0 would.
+	Raw bool
+}
+
+// Replay applies a recording, as written with Options.Record, to list in the
+// same way a running cache would have. The initial walk isn't part of a
+// recording, so list is normally empty.
+//
+// Modification times and symlink targets are read from disk as the events are
+// applied, and rescans walk the disk, so they reflect the disk at replay time.
+func Replay(in io.Reader, list fslist.FSList, opts ReplayOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fs := &FSCache{
+		fileList:  list,
+		ignore:    ignorer.NewGlobalIgnore(nil),
+		ctx:       ctx,
+		cancel:    cancel,
+		closeOnce: &sync.Once{},
+		wg:        &sync.WaitGroup{},
+		logger:    shared.Logger().With().Str("object", "replay").Logger(),
+	}
+
+	return watcher.ReadRecording(in, func(batch watcher.Batch) error {
+		events := batch.Events
+		if !opts.Raw {
+			events = watcher.Coalesce(events)
+		}
+
+		fs.apply(events)
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package fscache
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recording = `{"time":"2021-06-01T12:00:00Z","events":[{"path":"/r/src","type":"add","dir":true}]}
+{"time":"2021-06-01T12:00:00Z","events":[{"path":"/r/src/main.go","type":"add"},{"path":"/r/src/main.go~","type":"add"}]}
+{"time":"2021-06-01T12:00:01Z","events":[{"path":"/r/src/main.go~","type":"delete"},{"path":"/r/tmp","type":"add"},{"path":"/r/tmp","type":"delete"}]}
+{"time":"2021-06-01T12:00:02Z","events":[{"path":"/r/lib","old_path":"/r/src","type":"rename","dir":true}]}
+`
+
+func replayNames(t *testing.T, opts ReplayOptions) []string {
+	list, err := fslist.New(fslist.ModePebble, fslist.Options{})
+	require.NoError(t, err)
+	defer list.Close()
+
+	require.NoError(t, Replay(strings.NewReader(recording), list, opts))
+
+	names := []string{}
+	for data := range list.Fetch(fslist.ReadOptions{}) {
+		names = append(names, data.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestReplay(t *testing.T) {
+	expected := []string{"/r/lib", "/r/lib/main.go"}
+
+	assert.Equal(t, expected, replayNames(t, ReplayOptions{}))
+	assert.Equal(t, expected, replayNames(t, ReplayOptions{Raw: true}))
+}
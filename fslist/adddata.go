@@ -13,13 +13,34 @@ type AddData struct {
 	Name      string
 	UpdatedAt *time.Time
 	IsDir     bool
+
+	// Symlink is set for symbolic links, with Target holding where the link
+	// points. A followed link to a directory is also IsDir.
+	Symlink bool
+	Target  string
+
+	// Ignored is set by the FSList for entries matched by an ignore file, or
+	// below a directory which is. IgnoredGlobally is also set when it is only
+	// down to the global excludes file. Whatever is passed to Add is
+	// replaced.
+	Ignored         bool
+	IgnoredGlobally bool
 }
 
 func AddDataFromProtoFile(f *proto.File) AddData {
-	return AddData{
-		Name:  f.Name,
-		IsDir: f.Dir,
+	a := AddData{
+		Name:    f.Name,
+		IsDir:   f.Dir,
+		Symlink: f.Symlink,
+		Target:  f.Target,
 	}
+
+	if f.UpdatedAt != 0 {
+		updatedAt := time.Unix(f.UpdatedAt, 0).UTC()
+		a.UpdatedAt = &updatedAt
+	}
+
+	return a
 }
 
 func (a AddData) String() string {
@@ -34,16 +55,28 @@ func (a AddData) String() string {
 func (a AddData) MarshalZerologObject(e *zerolog.Event) {
 	e.Str("name", a.Name).Bool("isDir", a.IsDir)
 
+	if a.Symlink {
+		e.Str("target", a.Target)
+	}
+
 	if a.UpdatedAt != nil {
 		e.Time("updatedAt", *a.UpdatedAt)
 	}
 }
 
 func (a AddData) ToProtoFile() *proto.File {
-	return &proto.File{
-		Dir:  a.IsDir,
-		Name: a.Name,
+	f := &proto.File{
+		Dir:     a.IsDir,
+		Name:    a.Name,
+		Symlink: a.Symlink,
+		Target:  a.Target,
+	}
+
+	if a.UpdatedAt != nil && !a.UpdatedAt.IsZero() {
+		f.UpdatedAt = a.UpdatedAt.Unix()
 	}
+
+	return f
 }
 
 func (a AddData) pebbleKey() []byte {
@@ -59,6 +92,24 @@ func (a AddData) pebbleKey() []byte {
 	return key
 }
 
+// isDirKey reports whether key, from pebbleKey, belongs to a directory.
+func isDirKey(key []byte) bool {
+	return len(key) > 0 && key[len(key)-1] == '/'
+}
+
+// pebbleKeyName is the inverse of pebbleKey, returning the name and whether
+// it is a directory.
+func pebbleKeyName(key []byte) (string, bool) {
+	if !isDirKey(key) {
+		return string(key), false
+	}
+	if len(key) == 1 {
+		return "/", true
+	}
+
+	return string(key[:len(key)-1]), true
+}
+
 type ByPath []AddData
 
 func (s ByPath) Len() int {
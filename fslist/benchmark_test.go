@@ -1,6 +1,7 @@
 package fslist
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -15,9 +16,10 @@ func BenchmarkAdd(b *testing.B) {
 	for _, mode := range []Mode{
 		ModeSQL,
 		ModePebble,
+		ModeMemory,
 	} {
 		b.Run(fmt.Sprintf("%s_add", mode), func(b *testing.B) {
-			list, err := New(mode)
+			list, err := New(mode, Options{})
 			if err != nil {
 				b.Fatalf("Error creating fslist: %v", err)
 				return
@@ -31,3 +33,86 @@ func BenchmarkAdd(b *testing.B) {
 
 	}
 }
+
+// benchmarkData is a typical entry, with the mtime every walked entry has.
+func benchmarkData() AddData {
+	updatedAt := time.Date(2021, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	return AddData{Name: "/home/user/src/github.com/keyneston/fscache/fslist/pebble.go", UpdatedAt: &updatedAt}
+}
+
+// BenchmarkValueEncoding compares the binary values with the JSON ones used
+// before schema version 1.
+func BenchmarkValueEncoding(b *testing.B) {
+	data := benchmarkData()
+	key := data.pebbleKey()
+
+	b.Run("json_encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("binary_encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encodeValue(data)
+		}
+	})
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("json_decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeJSONValue(encoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	value := encodeValue(data)
+	b.Run("binary_decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeValue(key, value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPebbleFetch lists every entry, which is mostly decoding values.
+func BenchmarkPebbleFetch(b *testing.B) {
+	const entries = 10000
+
+	list, err := NewPebble(Options{})
+	if err != nil {
+		b.Fatalf("Error creating fslist: %v", err)
+	}
+	defer list.Close()
+
+	data := benchmarkData()
+	for i := 0; i < entries; i++ {
+		data.Name = fmt.Sprintf("/home/user/src/%08d.go", i)
+		if err := list.Add(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for range list.Fetch(ReadOptions{}) {
+			n++
+		}
+		if n != entries {
+			b.Fatalf("fetched %d entries, expected %d", n, entries)
+		}
+	}
+}
@@ -0,0 +1,805 @@
+package fslist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this file are run against every Mode, which should all behave
+// the same.
+var modes = []Mode{ModePebble, ModeSQL, ModeMemory}
+
+var testData = map[string]AddData{
+	"/foo/bar":           AddData{Name: "/foo/bar", IsDir: true},
+	"/foo/bar/baz":       AddData{Name: "/foo/bar/baz", IsDir: true},
+	"/foo/bar/baz/1.txt": AddData{Name: "/foo/bar/baz/1.txt", IsDir: false},
+	"/foo/bar/baz/2.txt": AddData{Name: "/foo/bar/baz/2.txt", IsDir: false},
+	"/foo/bar/qaz":       AddData{Name: "/foo/bar/qaz", IsDir: false},
+}
+
+var __allTestData []AddData
+
+func getTestData(names ...string) []AddData {
+	res := []AddData{}
+
+	for _, name := range names {
+		res = append(res, testData[name])
+	}
+	return res
+}
+
+func getAllTestData() []AddData {
+	if __allTestData != nil {
+		return __allTestData
+	}
+
+	__allTestData = make([]AddData, 0, len(testData))
+	for _, i := range testData {
+		__allTestData = append(__allTestData, i)
+	}
+
+	sort.Sort(ByPath(__allTestData))
+
+	return __allTestData
+}
+
+// forEachMode runs fn against a new, empty, FSList of every mode.
+func forEachMode(t *testing.T, fn func(t *testing.T, db FSList)) {
+	forEachModeWith(t, Options{}, fn)
+}
+
+// forEachModeWith is forEachMode with the FSLists created with opts.
+func forEachModeWith(t *testing.T, opts Options, fn func(t *testing.T, db FSList)) {
+	for _, mode := range modes {
+		t.Run(mode, func(t *testing.T) {
+			db, err := New(mode, opts)
+			require.NoError(t, err)
+			defer db.Close()
+
+			fn(t, db)
+		})
+	}
+}
+
+func fetchAll(db Reader, opts ReadOptions) []AddData {
+	res := []AddData{}
+	for i := range db.Fetch(opts) {
+		res = append(res, i)
+	}
+	return res
+}
+
+// writeIgnoreTree writes a .gitignore ignoring build to a new directory, and
+// returns the directory along with a tree using it.
+func writeIgnoreTree(t *testing.T) (string, []AddData) {
+	tmp, err := os.MkdirTemp("", "fslist-ignore-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+
+	gitignore := filepath.Join(tmp, ".gitignore")
+	require.NoError(t, os.WriteFile(gitignore, []byte("build\n"), 0644))
+
+	return tmp, []AddData{
+		{Name: tmp, IsDir: true},
+		{Name: gitignore},
+		{Name: filepath.Join(tmp, "build"), IsDir: true},
+		{Name: filepath.Join(tmp, "build", "out.bin")},
+		{Name: filepath.Join(tmp, "build.sh")},
+		{Name: filepath.Join(tmp, "src"), IsDir: true},
+		{Name: filepath.Join(tmp, "src", "main.go")},
+	}
+}
+
+func TestFetch(t *testing.T) {
+	type testCase struct {
+		name     string
+		testData []AddData
+		input    ReadOptions
+		expected []AddData
+	}
+
+	testCases := []testCase{
+		{
+			name:     "no_options",
+			testData: getAllTestData(),
+			expected: getAllTestData(),
+			input:    ReadOptions{},
+		},
+		{
+			name:     "specific item",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar/qaz"),
+			input:    ReadOptions{Prefix: "/foo/bar/qaz"},
+		},
+		{
+			name:     "subtree",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar/baz", "/foo/bar/baz/1.txt", "/foo/bar/baz/2.txt"),
+			input:    ReadOptions{Prefix: "/foo/bar/baz"},
+		},
+		{
+			name:     "duplicate adds",
+			testData: append(getAllTestData(), getAllTestData()...),
+			expected: getAllTestData(),
+			input:    ReadOptions{},
+		},
+		{
+			name:     "dirs only",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar", "/foo/bar/baz"),
+			input:    ReadOptions{DirsOnly: true},
+		},
+		{
+			name:     "files only",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar/baz/1.txt", "/foo/bar/baz/2.txt", "/foo/bar/qaz"),
+			input:    ReadOptions{FilesOnly: true},
+		},
+		{
+			name:     "limit",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar", "/foo/bar/baz"),
+			input:    ReadOptions{Limit: 2},
+		},
+		{
+			name:     "current dir first",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar/baz", "/foo/bar/baz/1.txt", "/foo/bar/baz/2.txt", "/foo/bar/qaz", "/foo/bar"),
+			input:    ReadOptions{Prefix: "/foo/bar", CurrentDir: "/foo/bar/baz"},
+		},
+		{
+			name:     "current dir with limit",
+			testData: getAllTestData(),
+			expected: getTestData("/foo/bar/qaz", "/foo/bar"),
+			input:    ReadOptions{Prefix: "/foo/bar", CurrentDir: "/foo/bar/qaz", Limit: 2},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			forEachMode(t, func(t *testing.T, db FSList) {
+				for _, d := range c.testData {
+					require.NoError(t, db.Add(d))
+				}
+
+				if diff := deep.Equal(c.expected, fetchAll(db, c.input)); diff != nil {
+					t.Errorf("db.Fetch(%#v) =\n%v", c.input, strings.Join(diff, "\n"))
+				}
+			})
+		})
+	}
+}
+
+func TestFetchIgnored(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		for _, d := range tree {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := func(opts ReadOptions) []string {
+			res := []string{}
+			for _, d := range fetchAll(db, opts) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			return res
+		}
+
+		// The ignored build directory is skipped, but not build.sh next to it.
+		assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}, names(ReadOptions{}))
+		assert.Equal(t, []string{"/.gitignore", "/build.sh", "/src/main.go"}, names(ReadOptions{FilesOnly: true}))
+		assert.Len(t, names(ReadOptions{NoIgnore: true}), len(tree))
+	})
+}
+
+func TestIgnoreFileChanged(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		gitignore := tree[1]
+
+		// Everything is there before the .gitignore.
+		for _, d := range append(tree[2:], tree[0]) {
+			require.NoError(t, db.Add(d))
+		}
+		require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "src", "out", "main.o")}))
+
+		names := func(opts ReadOptions) []string {
+			res := []string{}
+			for _, d := range fetchAll(db, opts) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			return res
+		}
+
+		require.NoError(t, db.Add(gitignore))
+		assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go", "/src/out/main.o"}, names(ReadOptions{}))
+
+		// Changing it frees build again, and ignores what it now matches.
+		require.NoError(t, os.WriteFile(gitignore.Name, []byte("out\n*.sh\n"), 0644))
+		require.NoError(t, db.Add(gitignore))
+		assert.Equal(t, []string{"", "/.gitignore", "/build", "/build/out.bin", "/src", "/src/main.go"}, names(ReadOptions{}))
+
+		// Entries moved or added below an ignored directory are ignored too.
+		require.NoError(t, db.Move(AddData{Name: filepath.Join(tmp, "build"), IsDir: true}, AddData{Name: filepath.Join(tmp, "src", "out", "build"), IsDir: true}))
+		require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "src", "out", "lib.o")}))
+		assert.Equal(t, []string{"", "/.gitignore", "/src", "/src/main.go"}, names(ReadOptions{}))
+
+		counts, err := db.Count(ReadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, Counts{Files: 2, Dirs: 2}, counts)
+
+		all := fetchAll(db, ReadOptions{NoIgnore: true})
+		assert.Len(t, all, 9)
+		for _, d := range all {
+			ignored := strings.HasPrefix(d.Name, filepath.Join(tmp, "src", "out")) || d.Name == filepath.Join(tmp, "build.sh")
+			assert.Equal(t, ignored, d.Ignored, d.Name)
+		}
+	})
+}
+
+func TestIgnoreSources(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "fslist-sources-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	global := filepath.Join(tmp, "global-ignore")
+	repo := filepath.Join(tmp, "repo")
+	files := map[string]string{
+		global:                           "*.swp\n",
+		filepath.Join(tmp, ".gitignore"): "repo/shared\n",
+		filepath.Join(repo, ".git", "info", "exclude"): "local\n",
+		filepath.Join(repo, ".ignore"):                 "*.log\n",
+	}
+	for file, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte(contents), 0644))
+	}
+
+	opts := Options{Ignore: IgnoreSources{
+		Files:        []string{".gitignore", ".ignore"},
+		Exclude:      true,
+		ExcludesFile: global,
+	}}
+	forEachModeWith(t, opts, func(t *testing.T, db FSList) {
+		for _, d := range []AddData{
+			{Name: tmp, IsDir: true},
+			{Name: filepath.Join(tmp, ".gitignore")},
+			{Name: filepath.Join(tmp, "a.swp")},
+			{Name: filepath.Join(tmp, "local")},
+			{Name: filepath.Join(tmp, "repo", "shared")},
+			{Name: repo, IsDir: true},
+			{Name: filepath.Join(repo, ".git"), IsDir: true},
+			{Name: filepath.Join(repo, ".ignore")},
+			{Name: filepath.Join(repo, "a.swp")},
+			{Name: filepath.Join(repo, "debug.log")},
+			{Name: filepath.Join(repo, "local")},
+			{Name: filepath.Join(repo, "main.go")},
+		} {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := []string{}
+		for _, d := range fetchAll(db, ReadOptions{}) {
+			names = append(names, strings.TrimPrefix(d.Name, tmp))
+		}
+
+		// The rules from outside the repository stop applying once its .git
+		// is added, and its exclude file only applies inside it. The .git
+		// itself is never shown.
+		assert.Equal(t, []string{"", "/.gitignore", "/local", "/repo", "/repo/.ignore", "/repo/main.go", "/repo/shared"}, names)
+
+		// Without its .git it is no longer a repository.
+		require.NoError(t, db.Delete(AddData{Name: filepath.Join(repo, ".git"), IsDir: true}))
+		names = []string{}
+		for _, d := range fetchAll(db, ReadOptions{}) {
+			names = append(names, strings.TrimPrefix(d.Name, tmp))
+		}
+		assert.Equal(t, []string{"", "/.gitignore", "/local", "/repo", "/repo/.ignore", "/repo/local", "/repo/main.go"}, names)
+	})
+}
+
+func TestReadIgnoreLayers(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "fslist-layers-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	global := filepath.Join(tmp, "global-ignore")
+	require.NoError(t, os.WriteFile(global, []byte("*.swp\nbuild/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, ".gitignore"), []byte("*.log\n"), 0644))
+
+	forEachModeWith(t, Options{Ignore: IgnoreSources{ExcludesFile: global}}, func(t *testing.T, db FSList) {
+		for _, d := range []AddData{
+			{Name: tmp, IsDir: true},
+			{Name: filepath.Join(tmp, ".gitignore")},
+			{Name: filepath.Join(tmp, ".config"), IsDir: true},
+			{Name: filepath.Join(tmp, ".config", "x")},
+			{Name: filepath.Join(tmp, ".env.local")},
+			{Name: filepath.Join(tmp, "a.log")},
+			{Name: filepath.Join(tmp, "b.swp")},
+			{Name: filepath.Join(tmp, "build"), IsDir: true},
+			{Name: filepath.Join(tmp, "build", "c.log")},
+			{Name: filepath.Join(tmp, "build", "out.bin")},
+			{Name: filepath.Join(tmp, "main.go")},
+		} {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := func(opts ReadOptions) []string {
+			opts.Prefix = tmp
+			res := []string{}
+			for _, d := range fetchAll(db, opts) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+
+			counts, err := db.Count(opts)
+			require.NoError(t, err)
+			assert.Equal(t, len(res), counts.Files+counts.Dirs, "Count(%+v)", opts)
+			return res
+		}
+
+		assert.Equal(t, []string{"", "/.config", "/.config/x", "/.env.local", "/.gitignore", "/main.go"}, names(ReadOptions{}))
+		assert.Equal(t, []string{"", "/main.go"}, names(ReadOptions{NoHidden: true}))
+
+		// Only what the global excludes file ignores comes back, the
+		// .gitignore still applies below a directory it ignores.
+		assert.Equal(t, []string{"", "/b.swp", "/build", "/build/out.bin", "/main.go"}, names(ReadOptions{NoGlobalIgnore: true, NoHidden: true}))
+		assert.Equal(t, []string{"", "/a.log", "/b.swp", "/build", "/build/c.log", "/build/out.bin", "/main.go"}, names(ReadOptions{NoIgnore: true, NoHidden: true}))
+		assert.Len(t, names(ReadOptions{NoIgnore: true}), 11)
+	})
+}
+
+func TestReadHiddenRoot(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		root := "/home/user/.dotfiles"
+		for _, d := range []AddData{
+			{Name: root, IsDir: true},
+			{Name: root + "/vimrc"},
+			{Name: root + "/.cache", IsDir: true},
+			{Name: root + "/.cache/x"},
+		} {
+			require.NoError(t, db.Add(d))
+		}
+
+		// Only what is below the root counts, with or without a prefix.
+		for _, prefix := range []string{"", root + "/"} {
+			opts := ReadOptions{Root: root, Prefix: prefix, NoHidden: true}
+			res := []string{}
+			for _, d := range fetchAll(db, opts) {
+				res = append(res, d.Name)
+			}
+			assert.ElementsMatch(t, []string{root, root + "/vimrc"}, res, "prefix %q", prefix)
+
+			counts, err := db.Count(opts)
+			require.NoError(t, err)
+			assert.Equal(t, Counts{Files: 1, Dirs: 1}, counts, "prefix %q", prefix)
+		}
+	})
+}
+
+func TestIgnoreFileRemoved(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		for _, d := range tree {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := func() []string {
+			res := []string{}
+			for _, d := range fetchAll(db, ReadOptions{}) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			return res
+		}
+
+		// Moving the .gitignore away stops it applying.
+		moved := AddData{Name: filepath.Join(tmp, "gitignore.txt")}
+		require.NoError(t, os.Rename(tree[1].Name, moved.Name))
+		require.NoError(t, db.Move(tree[1], moved))
+		assert.Equal(t, []string{"", "/build.sh", "/build", "/build/out.bin", "/gitignore.txt", "/src", "/src/main.go"}, names())
+
+		require.NoError(t, os.Rename(moved.Name, tree[1].Name))
+		require.NoError(t, db.Move(moved, tree[1]))
+		assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}, names())
+
+		// As does deleting it.
+		require.NoError(t, os.Remove(tree[1].Name))
+		require.NoError(t, db.Delete(tree[1]))
+		assert.Equal(t, []string{"", "/build.sh", "/build", "/build/out.bin", "/src", "/src/main.go"}, names())
+	})
+}
+
+func TestIgnoreBatchClosed(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		for _, d := range append(tree[2:], tree[0]) {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := func() []string {
+			res := []string{}
+			for _, d := range fetchAll(db, ReadOptions{}) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			return res
+		}
+
+		// A batch thrown away after adding the .gitignore leaves the rules as
+		// they were, so entries added afterwards aren't ignored by it.
+		b := db.NewBatch()
+		require.NoError(t, b.Add(tree[1]))
+		require.NoError(t, b.Close())
+
+		require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "build", "new.bin")}))
+		assert.Equal(t, []string{"", "/build.sh", "/build", "/build/new.bin", "/build/out.bin", "/src", "/src/main.go"}, names())
+
+		b = db.NewBatch()
+		require.NoError(t, b.Add(tree[1]))
+		require.NoError(t, b.Commit())
+		assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}, names())
+
+		// The same goes for deleting the directory holding it.
+		b = db.NewBatch()
+		require.NoError(t, b.Delete(tree[0]))
+		require.NoError(t, b.Close())
+
+		require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "build", "other.bin")}))
+		assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}, names())
+	})
+}
+
+func TestIgnoreFileBatchClosed(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		gitignore := tree[1]
+		for _, d := range tree {
+			require.NoError(t, db.Add(d))
+		}
+
+		names := func() []string {
+			res := []string{}
+			for _, d := range fetchAll(db, ReadOptions{}) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			return res
+		}
+		before := []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}
+
+		// Edits, deletes and moves of the .gitignore in a batch which is
+		// thrown away all leave its old rules in place.
+		require.NoError(t, os.WriteFile(gitignore.Name, []byte("*.sh\n"), 0644))
+		moved := AddData{Name: filepath.Join(tmp, "src", ".gitignore")}
+		for _, change := range []func(b Batch) error{
+			func(b Batch) error { return b.Add(gitignore) },
+			func(b Batch) error { return b.Delete(gitignore) },
+			func(b Batch) error { return b.Move(gitignore, moved) },
+		} {
+			b := db.NewBatch()
+			require.NoError(t, change(b))
+			require.NoError(t, b.Close())
+
+			require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "build", "new.bin")}))
+			assert.Equal(t, before, names())
+		}
+
+		// Committed, the edit applies.
+		require.NoError(t, db.Add(gitignore))
+		assert.Equal(t, []string{"", "/.gitignore", "/build", "/build/new.bin", "/build/out.bin", "/src", "/src/main.go"}, names())
+	})
+}
+
+func TestFetchQuery(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		tree = append(tree,
+			AddData{Name: filepath.Join(tmp, "src", "cache.go")},
+			AddData{Name: filepath.Join(tmp, "src", "cache_test.go")},
+			AddData{Name: filepath.Join(tmp, "build", "cache.go")},
+		)
+		for _, d := range tree {
+			require.NoError(t, db.Add(d))
+		}
+
+		// Matches may be ranked, so aren't necessarily in order.
+		names := func(opts ReadOptions) []string {
+			res := []string{}
+			for _, d := range fetchAll(db, opts) {
+				res = append(res, strings.TrimPrefix(d.Name, tmp))
+			}
+			sort.Strings(res)
+			return res
+		}
+
+		assert.Equal(t, []string{"/src/cache.go", "/src/cache_test.go", "/src/main.go"}, names(ReadOptions{Query: "go"}))
+		assert.Equal(t, []string{"/src/cache.go", "/src/cache_test.go"}, names(ReadOptions{Query: "cache go"}))
+		assert.Equal(t, []string{"/src/cache.go"}, names(ReadOptions{Query: "cache.go"}))
+		assert.Equal(t, []string{"/src/cache_test.go"}, names(ReadOptions{Query: "cach tes", FilesOnly: true}))
+		assert.Equal(t, []string{"/build/cache.go", "/src/cache.go"}, names(ReadOptions{Query: "cache.go", NoIgnore: true}))
+		assert.Equal(t, []string{"/src", "/src/cache.go", "/src/cache_test.go", "/src/main.go"}, names(ReadOptions{Query: "src", Prefix: tmp}))
+		assert.Len(t, names(ReadOptions{Query: "go", Limit: 2}), 2)
+
+		counts, err := db.Count(ReadOptions{Query: "cache"})
+		require.NoError(t, err)
+		assert.Equal(t, Counts{Files: 2}, counts)
+	})
+}
+
+func TestAddReplaces(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		first := time.Date(2021, 6, 1, 12, 0, 0, 123456789, time.UTC)
+		second := first.Add(time.Hour)
+
+		require.NoError(t, db.Add(AddData{Name: "/foo/link", UpdatedAt: &first, Symlink: true, Target: "/bar"}))
+		require.NoError(t, db.Add(AddData{Name: "/foo/dir", UpdatedAt: &first, IsDir: true}))
+		require.NoError(t, db.Add(AddData{Name: "/foo/link", UpdatedAt: &second}))
+
+		expected := []AddData{
+			{Name: "/foo/dir", UpdatedAt: &first, IsDir: true},
+			{Name: "/foo/link", UpdatedAt: &second},
+		}
+		if diff := deep.Equal(expected, fetchAll(db, ReadOptions{})); diff != nil {
+			t.Errorf("db.Fetch() after replacing =\n%v", strings.Join(diff, "\n"))
+		}
+		assert.Equal(t, Counts{Files: 1, Dirs: 1}, db.Totals())
+	})
+}
+
+func TestMove(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		for _, d := range getAllTestData() {
+			require.NoError(t, db.Add(d))
+		}
+		// A sibling sharing the moved directory's name as a prefix must stay put.
+		require.NoError(t, db.Add(AddData{Name: "/foo/bar/baz.txt"}))
+
+		require.NoError(t, db.Move(
+			AddData{Name: "/foo/bar/baz", IsDir: true},
+			AddData{Name: "/foo/bar/moved", IsDir: true},
+		))
+		require.NoError(t, db.Move(AddData{Name: "/foo/bar/qaz"}, AddData{Name: "/foo/qaz"}))
+		// Moving something unknown leaves it to the caller.
+		assert.Equal(t, ErrNotIndexed, db.Move(AddData{Name: "/foo/unknown"}, AddData{Name: "/foo/new"}))
+		assert.Equal(t, ErrNotIndexed, db.Move(AddData{Name: "/foo/bar/q", IsDir: true}, AddData{Name: "/foo/new", IsDir: true}))
+
+		expected := []AddData{
+			{Name: "/foo/bar", IsDir: true},
+			{Name: "/foo/bar/baz.txt"},
+			{Name: "/foo/bar/moved", IsDir: true},
+			{Name: "/foo/bar/moved/1.txt"},
+			{Name: "/foo/bar/moved/2.txt"},
+			{Name: "/foo/qaz"},
+		}
+		if diff := deep.Equal(expected, fetchAll(db, ReadOptions{})); diff != nil {
+			t.Errorf("db.Fetch() after Move =\n%v", strings.Join(diff, "\n"))
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		for _, d := range getAllTestData() {
+			require.NoError(t, db.Add(d))
+		}
+		require.NoError(t, db.Add(AddData{Name: "/foo/bar/baz.txt"}))
+
+		require.NoError(t, db.Delete(AddData{Name: "/foo/bar/baz", IsDir: true}))
+		require.NoError(t, db.Delete(AddData{Name: "/foo/bar/qaz"}))
+
+		expected := []AddData{
+			{Name: "/foo/bar", IsDir: true},
+			{Name: "/foo/bar/baz.txt"},
+		}
+		if diff := deep.Equal(expected, fetchAll(db, ReadOptions{})); diff != nil {
+			t.Errorf("db.Fetch() after Delete =\n%v", strings.Join(diff, "\n"))
+		}
+	})
+}
+
+func TestTotals(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		for _, d := range getAllTestData() {
+			require.NoError(t, db.Add(d))
+		}
+		// Replacing an entry doesn't count it twice.
+		require.NoError(t, db.Add(testData["/foo/bar/qaz"]))
+		assert.Equal(t, Counts{Files: 3, Dirs: 2}, db.Totals())
+		assert.Equal(t, 5, db.Len())
+
+		require.NoError(t, db.Add(AddData{Name: "/foo/bar/moved/1.txt"}))
+		require.NoError(t, db.Move(
+			AddData{Name: "/foo/bar/baz", IsDir: true},
+			AddData{Name: "/foo/bar/moved", IsDir: true},
+		))
+		assert.Equal(t, Counts{Files: 3, Dirs: 2}, db.Totals(), "moving on top of an entry replaces it")
+
+		require.NoError(t, db.Delete(AddData{Name: "/foo/bar/missing"}))
+		require.NoError(t, db.Delete(AddData{Name: "/foo/bar/moved", IsDir: true}))
+		assert.Equal(t, Counts{Files: 1, Dirs: 1}, db.Totals())
+	})
+}
+
+func TestCount(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		tmp, tree := writeIgnoreTree(t)
+		for _, d := range tree {
+			require.NoError(t, db.Add(d))
+		}
+
+		count := func(opts ReadOptions) Counts {
+			counts, err := db.Count(opts)
+			require.NoError(t, err)
+			return counts
+		}
+
+		assert.Equal(t, Counts{Files: 3, Dirs: 2}, count(ReadOptions{}))
+		assert.Equal(t, Counts{Files: 1, Dirs: 1}, count(ReadOptions{Prefix: filepath.Join(tmp, "src")}))
+		assert.Equal(t, Counts{Files: 3}, count(ReadOptions{FilesOnly: true, Limit: 1}))
+		assert.Equal(t, Counts{Dirs: 2}, count(ReadOptions{DirsOnly: true}))
+		assert.Equal(t, Counts{Files: 4, Dirs: 3}, count(ReadOptions{NoIgnore: true}))
+		assert.Equal(t, Counts{Files: 4, Dirs: 3}, db.Totals())
+		assert.Len(t, fetchAll(db, ReadOptions{}), 5)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		names := func() []string {
+			res := []string{}
+			for _, d := range fetchAll(db, ReadOptions{}) {
+				res = append(res, d.Name)
+			}
+			return res
+		}
+
+		b := db.NewBatch()
+		for _, d := range getAllTestData() {
+			require.NoError(t, b.Add(d))
+		}
+		// Changes see the ones made earlier in the batch.
+		require.NoError(t, b.Move(
+			AddData{Name: "/foo/bar/baz", IsDir: true},
+			AddData{Name: "/foo/bar/moved", IsDir: true},
+		))
+		require.NoError(t, b.Delete(AddData{Name: "/foo/bar/qaz"}))
+
+		assert.Empty(t, names(), "uncommitted changes are visible")
+		assert.Equal(t, Counts{}, db.Totals())
+
+		require.NoError(t, b.Commit())
+		assert.Equal(t, []string{"/foo/bar", "/foo/bar/moved", "/foo/bar/moved/1.txt", "/foo/bar/moved/2.txt"}, names())
+		assert.Equal(t, Counts{Files: 2, Dirs: 2}, db.Totals())
+
+		b = db.NewBatch()
+		require.NoError(t, b.Delete(AddData{Name: "/foo/bar", IsDir: true}))
+		require.NoError(t, b.Close())
+		assert.Len(t, names(), 4, "closed batch was applied")
+		assert.Equal(t, Counts{Files: 2, Dirs: 2}, db.Totals())
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	forEachMode(t, func(t *testing.T, db FSList) {
+		for _, d := range getAllTestData() {
+			require.NoError(t, db.Add(d))
+		}
+
+		r, err := db.Snapshot()
+		require.NoError(t, err)
+		defer r.Close()
+		seq := db.Seq()
+		assert.Equal(t, seq, r.Seq())
+
+		require.NoError(t, db.Delete(AddData{Name: "/foo/bar/baz", IsDir: true}))
+		require.NoError(t, db.Add(AddData{Name: "/foo/bar/new"}))
+		assert.Greater(t, db.Seq(), seq)
+
+		// The reader doesn't see anything committed after it was made.
+		assert.Equal(t, getAllTestData(), fetchAll(r, ReadOptions{}))
+		counts, err := r.Count(ReadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, Counts{Files: 3, Dirs: 2}, counts)
+		assert.Equal(t, seq, r.Seq())
+		assert.Len(t, fetchAll(db, ReadOptions{}), 3)
+
+		// Committing nothing isn't a change.
+		seq = db.Seq()
+		require.NoError(t, db.NewBatch().Commit())
+		assert.Equal(t, seq, db.Seq())
+	})
+}
+
+func TestOpen(t *testing.T) {
+	for _, mode := range []Mode{ModePebble, ModeSQL} {
+		t.Run(mode, func(t *testing.T) {
+			location, err := os.MkdirTemp("", fmt.Sprintf("open-%s-*", mode))
+			require.NoError(t, err)
+			defer os.RemoveAll(location)
+
+			_, tree := writeIgnoreTree(t)
+
+			db, err := Open(mode, location, Options{})
+			require.NoError(t, err)
+			for _, d := range tree {
+				require.NoError(t, db.Add(d))
+			}
+			expected := fetchAll(db, ReadOptions{})
+			require.NoError(t, db.Close())
+
+			// Everything is still there after reopening, including the
+			// counters and the .gitignore rules.
+			db, err = Open(mode, location, Options{})
+			require.NoError(t, err)
+			defer db.Close()
+
+			if diff := deep.Equal(expected, fetchAll(db, ReadOptions{})); diff != nil {
+				t.Errorf("db.Fetch() after reopening =\n%v", strings.Join(diff, "\n"))
+			}
+			assert.Equal(t, Counts{Files: 4, Dirs: 3}, db.Totals())
+		})
+	}
+}
+
+func TestOpenRepos(t *testing.T) {
+	for _, mode := range []Mode{ModePebble, ModeSQL} {
+		t.Run(mode, func(t *testing.T) {
+			location, err := os.MkdirTemp("", fmt.Sprintf("open-repos-%s-*", mode))
+			require.NoError(t, err)
+			defer os.RemoveAll(location)
+
+			tmp, err := os.MkdirTemp("", "open-repos-tree-*")
+			require.NoError(t, err)
+			defer os.RemoveAll(tmp)
+
+			repo := filepath.Join(tmp, "repo")
+			exclude := filepath.Join(repo, ".git", "info", "exclude")
+			require.NoError(t, os.MkdirAll(filepath.Dir(exclude), 0755))
+			require.NoError(t, os.WriteFile(exclude, []byte("local\n"), 0644))
+			// Only the .git in the index makes a repository, not one on disk.
+			require.NoError(t, os.MkdirAll(filepath.Join(tmp, "other", ".git", "info"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(tmp, "other", ".git", "info", "exclude"), []byte("local\n"), 0644))
+
+			opts := Options{Ignore: IgnoreSources{Exclude: true}}
+			db, err := Open(mode, location, opts)
+			require.NoError(t, err)
+			for _, d := range []AddData{
+				{Name: tmp, IsDir: true},
+				{Name: repo, IsDir: true},
+				{Name: filepath.Join(repo, ".git"), IsDir: true},
+				{Name: filepath.Join(repo, "local")},
+				{Name: filepath.Join(repo, "main.go")},
+				{Name: filepath.Join(tmp, "other"), IsDir: true},
+				{Name: filepath.Join(tmp, "other", "local")},
+			} {
+				require.NoError(t, db.Add(d))
+			}
+			require.NoError(t, db.Close())
+
+			names := func(db FSList) []string {
+				res := []string{}
+				for _, d := range fetchAll(db, ReadOptions{}) {
+					res = append(res, strings.TrimPrefix(d.Name, tmp))
+				}
+				return res
+			}
+
+			// The repository is found again from its .git entry, and a
+			// change to its exclude file is picked up on opening.
+			require.NoError(t, os.WriteFile(exclude, []byte("main.go\n"), 0644))
+			db, err = Open(mode, location, opts)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"", "/other", "/other/local", "/repo", "/repo/local"}, names(db))
+
+			require.NoError(t, db.Add(AddData{Name: filepath.Join(repo, "main.go")}))
+			assert.Equal(t, []string{"", "/other", "/other/local", "/repo", "/repo/local"}, names(db))
+			require.NoError(t, db.Close())
+		})
+	}
+}
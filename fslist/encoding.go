@@ -0,0 +1,142 @@
+package fslist
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Values in a PebbleList are encoded as:
+//
+//	version   byte, valueVersion
+//	flags     byte, see flagDir etc.
+//	seconds   varint, only with flagUpdatedAt
+//	nanos     uvarint, only with flagUpdatedAt
+//	target    uvarint length then bytes, only with flagSymlink
+//
+// The name isn't stored, as it is already the key.
+const valueVersion = 1
+
+const (
+	flagDir byte = 1 << iota
+	flagSymlink
+	flagUpdatedAt
+	flagIgnored
+	flagIgnoredGlobally
+)
+
+var errShortValue = errors.New("value too short")
+
+// encodeValue encodes data for storage under data.pebbleKey().
+func encodeValue(data AddData) []byte {
+	buf := make([]byte, 2+3*binary.MaxVarintLen64+len(data.Target))
+	buf[0] = valueVersion
+	n := 2
+
+	if data.IsDir {
+		buf[1] |= flagDir
+	}
+	if data.UpdatedAt != nil {
+		buf[1] |= flagUpdatedAt
+		n += binary.PutVarint(buf[n:], data.UpdatedAt.Unix())
+		n += binary.PutUvarint(buf[n:], uint64(data.UpdatedAt.Nanosecond()))
+	}
+	if data.Ignored {
+		buf[1] |= flagIgnored
+	}
+	if data.IgnoredGlobally {
+		buf[1] |= flagIgnoredGlobally
+	}
+	if data.Symlink {
+		buf[1] |= flagSymlink
+		n += binary.PutUvarint(buf[n:], uint64(len(data.Target)))
+		n += copy(buf[n:], data.Target)
+	}
+
+	return buf[:n]
+}
+
+// decodeValue decodes a value stored under key by encodeValue.
+func decodeValue(key, value []byte) (AddData, error) {
+	if len(value) < 2 {
+		return AddData{}, errShortValue
+	}
+	if value[0] != valueVersion {
+		return AddData{}, fmt.Errorf("unknown value version %d", value[0])
+	}
+
+	flags := value[1]
+	rest := value[2:]
+
+	data := AddData{
+		IsDir:           flags&flagDir != 0,
+		Ignored:         flags&flagIgnored != 0,
+		IgnoredGlobally: flags&flagIgnoredGlobally != 0,
+	}
+	data.Name, _ = pebbleKeyName(key)
+
+	if flags&flagUpdatedAt != 0 {
+		secs, n := binary.Varint(rest)
+		if n <= 0 {
+			return AddData{}, errShortValue
+		}
+		rest = rest[n:]
+
+		nanos, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return AddData{}, errShortValue
+		}
+		rest = rest[n:]
+
+		updatedAt := time.Unix(secs, int64(nanos)).UTC()
+		data.UpdatedAt = &updatedAt
+	}
+
+	if flags&flagSymlink != 0 {
+		length, n := binary.Uvarint(rest)
+		if n <= 0 || uint64(len(rest)-n) < length {
+			return AddData{}, errShortValue
+		}
+
+		data.Symlink = true
+		data.Target = string(rest[n : n+int(length)])
+	}
+
+	return data, nil
+}
+
+// valueIgnored returns the Ignored and IgnoredGlobally flags of a value from
+// encodeValue, without decoding the rest of it.
+func valueIgnored(value []byte) (ignored, globally bool) {
+	if len(value) < 2 {
+		return false, false
+	}
+
+	return value[1]&flagIgnored != 0, value[1]&flagIgnoredGlobally != 0
+}
+
+// setValueIgnored returns a copy of a value from encodeValue with the Ignored
+// and IgnoredGlobally flags set to ignored and globally.
+func setValueIgnored(value []byte, ignored, globally bool) []byte {
+	value = append([]byte{}, value...)
+	if len(value) > 1 {
+		value[1] &^= flagIgnored | flagIgnoredGlobally
+		if ignored {
+			value[1] |= flagIgnored
+		}
+		if globally {
+			value[1] |= flagIgnoredGlobally
+		}
+	}
+
+	return value
+}
+
+// decodeJSONValue decodes the JSON values written before schema version 1.
+func decodeJSONValue(value []byte) (AddData, error) {
+	var data AddData
+	err := json.Unmarshal(value, &data)
+	return data, err
+}
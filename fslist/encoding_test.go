@@ -0,0 +1,66 @@
+package fslist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueEncoding(t *testing.T) {
+	updatedAt := time.Date(2021, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	before := time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, data := range []AddData{
+		{Name: "/foo/bar.txt"},
+		{Name: "/foo/bar", IsDir: true, UpdatedAt: &updatedAt},
+		{Name: "/", IsDir: true},
+		{Name: "/foo/old", UpdatedAt: &before},
+		{Name: "/foo/link", Symlink: true, Target: "../bar", UpdatedAt: &updatedAt},
+		{Name: "/foo/dir-link", IsDir: true, Symlink: true, Target: "/data/src"},
+		{Name: "/foo/dangling", Symlink: true},
+		{Name: "/foo/build", IsDir: true, Ignored: true},
+		{Name: "/foo/a.swp", Ignored: true, IgnoredGlobally: true},
+	} {
+		decoded, err := decodeValue(data.pebbleKey(), encodeValue(data))
+		require.NoError(t, err, data.Name)
+		assert.Equal(t, data, decoded)
+	}
+}
+
+func TestSetValueIgnored(t *testing.T) {
+	updatedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := AddData{Name: "/foo/link", Symlink: true, Target: "../bar", UpdatedAt: &updatedAt}
+	value := encodeValue(data)
+
+	ignored := setValueIgnored(value, true, true)
+	isIgnored, globally := valueIgnored(ignored)
+	assert.True(t, isIgnored)
+	assert.True(t, globally)
+	isIgnored, _ = valueIgnored(value)
+	assert.False(t, isIgnored, "the value passed in is left alone")
+
+	decoded, err := decodeValue(data.pebbleKey(), ignored)
+	require.NoError(t, err)
+	data.Ignored, data.IgnoredGlobally = true, true
+	assert.Equal(t, data, decoded)
+
+	isIgnored, globally = valueIgnored(setValueIgnored(ignored, true, false))
+	assert.True(t, isIgnored)
+	assert.False(t, globally)
+	assert.Equal(t, value, setValueIgnored(ignored, false, false))
+}
+
+func TestDecodeValueInvalid(t *testing.T) {
+	updatedAt := time.Now()
+	value := encodeValue(AddData{Name: "/foo", Symlink: true, Target: "/bar", UpdatedAt: &updatedAt})
+
+	for i := 0; i < len(value); i++ {
+		_, err := decodeValue([]byte("/foo"), value[:i])
+		assert.Error(t, err, "truncated to %d bytes", i)
+	}
+
+	_, err := decodeValue([]byte("/foo"), []byte(`{"Name":"/foo"}`))
+	assert.Error(t, err)
+}
@@ -1,58 +1,550 @@
 package fslist
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/keyneston/fscache/internal/shared"
-	"github.com/monochromegane/go-gitignore"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/rs/zerolog"
 )
 
+// DefaultIgnoreFile is the ignore file read in every directory when
+// IgnoreSources.Files is empty.
+const DefaultIgnoreFile = ".gitignore"
+
+// IgnoreSources are where an FSList reads its ignore rules from. The zero
+// value only reads .gitignore files.
+type IgnoreSources struct {
+	// Files are the names of the ignore files read in every directory, such
+	// as .gitignore, .ignore or .fdignore. In the same directory later ones
+	// take precedence. Empty means DefaultIgnoreFile.
+	Files []string
+	// Exclude reads .git/info/exclude in every git repository, which comes
+	// below the ignore files of the repository in precedence.
+	Exclude bool
+	// ExcludesFile has rules applying everywhere, at the lowest precedence,
+	// as with git's core.excludesFile. See ignorer.ExcludesFile.
+	ExcludesFile string
+}
+
+func (s IgnoreSources) files() []string {
+	if len(s.Files) == 0 {
+		return []string{DefaultIgnoreFile}
+	}
+	return s.Files
+}
+
+// ignoreMatcher is implemented by *ignorer.Rules.
+type ignoreMatcher interface {
+	Match(name string, isDir bool) ignorer.Result
+}
+
+// IgnoreCache holds the ignore rules which apply to the index, and matches
+// them the way git does. The closest rules to a path take precedence, and
+// rules from outside a repository don't apply inside it. It isn't safe for
+// concurrent use, which is left to the FSList writing to it. A batch changes
+// a clone, see batchIgnores, so the rules only change once it is committed.
 type IgnoreCache struct {
-	cache map[string]gitignore.IgnoreMatcher
+	sources IgnoreSources
+	// cache holds the rules of the ignore files in each directory, by the
+	// name of the file.
+	cache map[string]map[string]ignoreMatcher
+	// repos holds the roots of git repositories, with the rules of their
+	// exclude file when it is read.
+	repos map[string]ignoreMatcher
+	// global holds the rules of sources.ExcludesFile.
+	global ignoreMatcher
+
+	logger *zerolog.Logger
 }
 
-func (ic *IgnoreCache) Add(file string) error {
-	if ic.cache == nil {
-		ic.cache = make(map[string]gitignore.IgnoreMatcher)
+func newIgnoreCache(sources IgnoreSources, logger *zerolog.Logger) *IgnoreCache {
+	ic := &IgnoreCache{
+		sources: sources,
+		cache:   map[string]map[string]ignoreMatcher{},
+		repos:   map[string]ignoreMatcher{},
+		logger:  logger,
 	}
 
-	superior := ic.findSuperior(file)
-	shared.Logger().Trace().Str("module", "IgnoreCache").Str("file", file).Strs("superior", superior).Msg("")
+	if sources.ExcludesFile != "" {
+		if rules, err := ignorer.ReadRules(sources.ExcludesFile); err == nil {
+			ic.global = rules
+		} else if !os.IsNotExist(err) {
+			logger.Warn().Err(err).Str("file", sources.ExcludesFile).Msg("unable to load excludes file")
+		}
+	}
+
+	return ic
+}
+
+// isIgnoreFile reports whether rules are read from the file called name.
+func (ic *IgnoreCache) isIgnoreFile(name string) bool {
+	base := filepath.Base(name)
+	for _, file := range ic.sources.files() {
+		if base == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Add reads the rules in file, replacing any read from it before.
+func (ic *IgnoreCache) Add(file string) error {
+	ic.logger.Trace().Str("module", "IgnoreCache").Str("file", file).Msg("adding")
 
-	matcher, err := gitignore.NewGitIgnore(file, ic.findSuperior(file)...)
+	rules, err := ignorer.ReadRules(file)
 	if err != nil {
 		return err
 	}
 
-	ic.cache[filepath.Dir(file)] = matcher
+	dir, base := filepath.Dir(file), filepath.Base(file)
+	files := map[string]ignoreMatcher{base: rules}
+	for name, m := range ic.cache[dir] {
+		if name != base {
+			files[name] = m
+		}
+	}
+	ic.cache[dir] = files
+
 	return nil
 }
 
-// Get finds the closest gitignore file. If no git ignore files exist above the
-// input, then it returns nil.
-func (ic *IgnoreCache) Get(file string) gitignore.IgnoreMatcher {
-	segments := strings.Split(file, "/")
+// Reload reads file again after it has changed. If it can no longer be read
+// its old rules are removed, as they no longer apply.
+func (ic *IgnoreCache) Reload(file string) error {
+	if err := ic.Add(file); err != nil {
+		ic.Remove(file)
+		return err
+	}
 
-	for i := len(segments); i > 0; i-- {
-		if matcher, ok := ic.cache[strings.Join(segments[0:i], "/")]; ok {
-			return matcher
+	return nil
+}
+
+// Remove drops the rules read from file.
+func (ic *IgnoreCache) Remove(file string) {
+	dir, base := filepath.Dir(file), filepath.Base(file)
+	if _, ok := ic.cache[dir][base]; !ok {
+		return
+	}
+
+	files := map[string]ignoreMatcher{}
+	for name, m := range ic.cache[dir] {
+		if name != base {
+			files[name] = m
 		}
 	}
 
-	return nil
+	if len(files) == 0 {
+		delete(ic.cache, dir)
+	} else {
+		ic.cache[dir] = files
+	}
+}
+
+// clone returns a copy of ic which can be changed without changing ic. The
+// rules of each directory are shared, which is why Add and Remove replace
+// them instead of changing them.
+func (ic *IgnoreCache) clone() *IgnoreCache {
+	c := *ic
+
+	c.cache = make(map[string]map[string]ignoreMatcher, len(ic.cache))
+	for dir, files := range ic.cache {
+		c.cache[dir] = files
+	}
+
+	c.repos = make(map[string]ignoreMatcher, len(ic.repos))
+	for dir, rules := range ic.repos {
+		c.repos[dir] = rules
+	}
+
+	return &c
+}
+
+// batchIgnores are the rules as a batch sees them. They start out as the
+// list's, and are cloned on the batch's first change to them, which the
+// batch swaps into the list when it is committed. A batch which is thrown
+// away, or fails to commit, leaves the list's rules as they were.
+type batchIgnores struct {
+	*IgnoreCache
+	// changed is set once IgnoreCache is the batch's own clone.
+	changed bool
+}
+
+// change returns the batch's own rules to change.
+func (b *batchIgnores) change() *IgnoreCache {
+	if !b.changed {
+		b.IgnoreCache = b.IgnoreCache.clone()
+		b.changed = true
+	}
+
+	return b.IgnoreCache
+}
+
+// repoRoot reports whether name is the .git of a repository, returning the
+// root of the repository. A repository is only known about once its .git is
+// in the index, so nothing has to be checked on disk.
+func repoRoot(name string) (string, bool) {
+	if filepath.Base(name) != ".git" {
+		return "", false
+	}
+
+	return filepath.Dir(name), true
+}
+
+// addRepo adds dir as the root of a git repository, reading its exclude file.
+// It reports whether that changes the rules for what is below dir.
+func (ic *IgnoreCache) addRepo(dir string) bool {
+	if _, ok := ic.repos[dir]; ok {
+		return false
+	}
+
+	ic.repos[dir] = nil
+	if ic.sources.Exclude {
+		exclude := ignorer.InfoExclude(dir)
+		if rules, err := ignorer.ReadRules(exclude); err == nil {
+			ic.repos[dir] = rules
+		} else if !os.IsNotExist(err) {
+			ic.logger.Warn().Err(err).Str("file", exclude).Msg("unable to load exclude file")
+		}
+	}
+
+	return true
+}
+
+// removeRepo drops dir as the root of a git repository, as when its .git has
+// been deleted. It reports whether that changes the rules for what is below
+// dir.
+func (ic *IgnoreCache) removeRepo(dir string) bool {
+	if _, ok := ic.repos[dir]; !ok {
+		return false
+	}
+
+	delete(ic.repos, dir)
+	return true
+}
+
+// removeDir drops the rules from in or below dir, as when it has been
+// deleted.
+func (ic *IgnoreCache) removeDir(dir string) {
+	for d := range ic.cache {
+		if d == dir || isBelow(d, dir) {
+			delete(ic.cache, d)
+		}
+	}
+
+	for d := range ic.repos {
+		if d == dir || isBelow(d, dir) {
+			delete(ic.repos, d)
+		}
+	}
+}
+
+// move updates the rules after from has been moved to to, taking the moved
+// entries under their new names. It returns the directories outside of to
+// whose rules have changed.
+func (ic *IgnoreCache) move(from, to AddData, moved []AddData) []string {
+	changed := []string{}
+	if from.IsDir {
+		ic.removeDir(from.Name)
+	} else if ic.isIgnoreFile(from.Name) {
+		ic.Remove(from.Name)
+		changed = append(changed, filepath.Dir(from.Name))
+	}
+	if dir, ok := repoRoot(from.Name); ok && ic.removeRepo(dir) {
+		changed = append(changed, dir)
+	}
+
+	for _, data := range moved {
+		if dir, ok := repoRoot(data.Name); ok {
+			ic.addRepo(dir)
+		} else if !data.IsDir && ic.isIgnoreFile(data.Name) {
+			ic.tryAdd(data.Name)
+		}
+	}
+
+	// A directory brings its entries along, but a file moved onto an ignore
+	// file changes the rules for its neighbours, and a .git moved into a
+	// directory makes it a repository.
+	if !from.IsDir && ic.isIgnoreFile(to.Name) {
+		changed = append(changed, filepath.Dir(to.Name))
+	}
+	if dir, ok := repoRoot(to.Name); ok {
+		changed = append(changed, dir)
+	}
+
+	return changed
+}
+
+// load adds the ignore files and repositories found in an existing index,
+// logging the files which can't be read. repos are the roots, found from the
+// .git entries in the index.
+func (ic *IgnoreCache) load(files, repos []string) {
+	for _, dir := range repos {
+		ic.addRepo(dir)
+	}
+
+	// Files which can't be read were most likely deleted while fscache
+	// wasn't running, in which case the reconcile will remove them.
+	for _, file := range files {
+		ic.tryAdd(file)
+	}
 }
 
-func (ic *IgnoreCache) findSuperior(file string) []string {
-	res := []string{}
+// state sums up what the ignore flags depend on besides the index: which
+// ignore files are read, and the exclude files. The ignore files and
+// repositories themselves are entries, so are kept in step with the index.
+func (ic *IgnoreCache) state() []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "files %q\n", ic.sources.files())
+	writeFileState(h, ic.sources.ExcludesFile)
 
-	segments := strings.SplitAfter(filepath.Clean(file), "/")
-	for i := range segments {
-		name := filepath.Join(segments[0:i]...)
-		if _, ok := ic.cache[name]; ok {
-			res = append(res, name)
+	if ic.sources.Exclude {
+		repos := make([]string, 0, len(ic.repos))
+		for dir := range ic.repos {
+			repos = append(repos, dir)
 		}
+		sort.Strings(repos)
+
+		for _, dir := range repos {
+			writeFileState(h, ignorer.InfoExclude(dir))
+		}
+	}
+
+	return h.Sum(nil)
+}
+
+// writeFileState writes the name and contents of file to w, or that it can't
+// be read.
+func writeFileState(w io.Writer, file string) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(w, "%q -\n", file)
+		return
+	}
+
+	fmt.Fprintf(w, "%q %d\n", file, len(contents))
+	w.Write(contents)
+}
+
+// tryAdd reads file, or reads it again if it was already added, logging
+// instead of failing when it can't be read.
+func (ic *IgnoreCache) tryAdd(file string) {
+	if err := ic.Reload(file); err != nil {
+		ic.logger.Warn().Err(err).Str("file", file).Msg("unable to load ignore file")
+	}
+}
+
+// isBelow reports whether path is inside dir, and not dir itself.
+func isBelow(path, dir string) bool {
+	return path != dir && strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// relativeTo returns path relative to dir, which it is below.
+func relativeTo(dir, path string) string {
+	return strings.TrimPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// Ignored reports whether data is ignored by the rules above it, not taking
+// into account whether a directory above it is ignored.
+func (ic *IgnoreCache) Ignored(data AddData) bool {
+	return ic.ignored(data, true)
+}
+
+// ignored is Ignored, leaving out the global rules unless global is set.
+func (ic *IgnoreCache) ignored(data AddData, global bool) bool {
+	// A .git is only indexed so repositories can be found, git itself never
+	// shows it.
+	if _, ok := repoRoot(data.Name); ok {
+		return true
+	}
+
+	// The global rules are relative to the repository data is in.
+	base := "/"
+
+	for dir := data.Name; dir != filepath.Dir(dir); {
+		dir = filepath.Dir(dir)
+		name := relativeTo(dir, data.Name)
+
+		if result := ic.matchDir(dir, name, data.IsDir); result != ignorer.NoMatch {
+			return result == ignorer.Ignore
+		}
+
+		if exclude, ok := ic.repos[dir]; ok {
+			if exclude != nil {
+				if result := exclude.Match(name, data.IsDir); result != ignorer.NoMatch {
+					return result == ignorer.Ignore
+				}
+			}
+
+			base = dir
+			break
+		}
+	}
+
+	return global && ic.global != nil && ic.global.Match(relativeTo(base, data.Name), data.IsDir) == ignorer.Ignore
+}
+
+// matchDir matches name against the ignore files in dir, the one with the
+// highest precedence first.
+func (ic *IgnoreCache) matchDir(dir, name string, isDir bool) ignorer.Result {
+	rules := ic.cache[dir]
+	if rules == nil {
+		return ignorer.NoMatch
+	}
+
+	files := ic.sources.files()
+	for i := len(files) - 1; i >= 0; i-- {
+		if m := rules[files[i]]; m != nil {
+			if result := m.Match(name, isDir); result != ignorer.NoMatch {
+				return result
+			}
+		}
+	}
+
+	return ignorer.NoMatch
+}
+
+// IgnoredPath reports whether data, or any directory above it, is ignored. It
+// is for entries found out of order, as a walk in order skips everything below
+// an ignored directory instead.
+func (ic *IgnoreCache) IgnoredPath(data AddData) bool {
+	return ic.ignoredPath(data, true)
+}
+
+// ignoredPath is IgnoredPath, leaving out the global rules unless global is
+// set.
+func (ic *IgnoreCache) ignoredPath(data AddData, global bool) bool {
+	if ic.ignored(data, global) {
+		return true
+	}
+
+	for dir := filepath.Dir(data.Name); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if ic.ignored(AddData{Name: dir, IsDir: true}, global) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setIgnored sets the Ignored and IgnoredGlobally flags of data, which may be
+// found out of order, as with IgnoredPath.
+func (ic *IgnoreCache) setIgnored(data *AddData) {
+	data.Ignored = ic.ignoredPath(*data, true)
+	data.IgnoredGlobally = data.Ignored && ic.global != nil && !ic.ignoredPath(*data, false)
+}
+
+// flagWalker works out the Ignored and IgnoredGlobally flags of the entries
+// visited in a walk, see ignoreWalker. It takes a second walk without the
+// global rules to tell whether only they ignore an entry.
+type flagWalker struct {
+	all, local *ignoreWalker
+}
+
+// newFlagWalker starts a walk over root and everything below it.
+func newFlagWalker(ic *IgnoreCache, root string) *flagWalker {
+	w := &flagWalker{all: newIgnoreWalker(ic, root, true)}
+	if ic.global != nil {
+		w.local = newIgnoreWalker(ic, root, false)
+	}
+
+	return w
+}
+
+// flags returns the Ignored and IgnoredGlobally flags of the entry stored
+// under key. Keys have to be passed in order.
+func (w *flagWalker) flags(key []byte) (ignored, globally bool) {
+	ignored = w.all.ignored(key)
+	if w.local != nil {
+		globally = !w.local.ignored(key) && ignored
+	}
+
+	return ignored, globally
+}
+
+// ignoreWalker works out whether entries are ignored while they are visited
+// in key order, in which everything below a directory follows it. The
+// contents of an ignored directory are ignored without being matched.
+type ignoreWalker struct {
+	ic *IgnoreCache
+	// global includes the global rules.
+	global bool
+	// skip is the key of the ignored directory being walked through.
+	skip []byte
+	// dirs are the keys of the directories above the entry being visited
+	// which aren't ignored, the closest last.
+	dirs [][]byte
+}
+
+// newIgnoreWalker starts a walk over root and everything below it, taking
+// into account whether a directory above root is ignored.
+func newIgnoreWalker(ic *IgnoreCache, root string, global bool) *ignoreWalker {
+	w := &ignoreWalker{ic: ic, global: global}
+
+	parent := AddData{Name: filepath.Dir(root), IsDir: true}
+	if parent.Name != root && ic.ignoredPath(parent, global) {
+		w.skip = parent.pebbleKey()
+	} else {
+		w.dirs = append(w.dirs, parent.pebbleKey())
+	}
+
+	return w
+}
+
+// ignored reports whether the entry stored under key is ignored. Keys have to
+// be passed in order.
+func (w *ignoreWalker) ignored(key []byte) bool {
+	if len(w.skip) > 0 && bytes.HasPrefix(key, w.skip) {
+		return true
+	}
+
+	for len(w.dirs) > 1 && !bytes.HasPrefix(key, w.dirs[len(w.dirs)-1]) {
+		w.dirs = w.dirs[:len(w.dirs)-1]
+	}
+
+	name, isDir := pebbleKeyName(key)
+	if name != "/" && w.dirIgnored(filepath.Dir(name)) {
+		return true
+	}
+
+	if !w.ic.ignored(AddData{Name: name, IsDir: isDir}, w.global) {
+		if isDir {
+			w.dirs = append(w.dirs, append([]byte{}, key...))
+		}
+		return false
+	}
+
+	if isDir {
+		w.skip = append([]byte{}, key...)
+	}
+	return true
+}
+
+// dirIgnored reports whether dir, which an entry is in, is ignored. It is
+// normally the last of dirs, but may not have been visited when it is missing
+// from the index, in which case it is checked here.
+func (w *ignoreWalker) dirIgnored(dir string) bool {
+	key := AddData{Name: dir, IsDir: true}.pebbleKey()
+	if bytes.Equal(key, w.dirs[len(w.dirs)-1]) || filepath.Dir(dir) == dir {
+		return false
+	}
+
+	if w.dirIgnored(filepath.Dir(dir)) {
+		return true
+	}
+
+	if w.ic.ignored(AddData{Name: dir, IsDir: true}, w.global) {
+		w.skip = key
+		return true
 	}
 
-	return res
+	w.dirs = append(w.dirs, key)
+	return false
 }
@@ -1,81 +1,214 @@
 package fslist
 
 import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/monochromegane/go-gitignore"
+	"github.com/keyneston/fscache/ignorer"
+	"github.com/keyneston/fscache/internal/shared"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-type fakeMatcher struct {
-	name string
-}
+// fakeMatcher returns the result for each name it has one for, which are
+// relative to the directory its rules apply to.
+type fakeMatcher map[string]ignorer.Result
 
-func (fakeMatcher) Match(string, bool) bool {
-	return true
+func (m fakeMatcher) Match(name string, isDir bool) ignorer.Result {
+	return m[name]
 }
 
-func TestIgnoreCache_Get(t *testing.T) {
-	ic := buildFakeIC("/foo/bar/baz", "/foo/bar/baz/qaz")
-
-	type testCase struct {
-		input    string
-		expected string
+func TestIgnoreCache_Ignored(t *testing.T) {
+	ic := newIgnoreCache(IgnoreSources{Files: []string{".gitignore", ".ignore"}}, shared.Logger())
+	ic.cache["/"] = map[string]ignoreMatcher{
+		".gitignore": fakeMatcher{"repo/a": ignorer.Ignore, "b": ignorer.Ignore},
+	}
+	ic.cache["/repo"] = map[string]ignoreMatcher{
+		".gitignore": fakeMatcher{"c": ignorer.Ignore, "d": ignorer.Ignore, "sub/e": ignorer.Ignore},
+		".ignore":    fakeMatcher{"d": ignorer.Include},
 	}
-	testCases := []testCase{
-		{input: "/foo/bar/baz", expected: "/foo/bar/baz"},
-		{input: "/foo/bar/baz/foo/foo", expected: "/foo/bar/baz"},
-		{input: "/foo/bar/baz/qaz/foo", expected: "/foo/bar/baz/qaz"},
-		{input: "/", expected: ""},
+	ic.cache["/repo/sub"] = map[string]ignoreMatcher{
+		".gitignore": fakeMatcher{"e": ignorer.Include},
+	}
+	ic.repos["/repo"] = fakeMatcher{"f": ignorer.Ignore, "c": ignorer.Include}
+	ic.global = fakeMatcher{"g": ignorer.Ignore, "repo/g": ignorer.Ignore, "b": ignorer.Include}
+
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		// Rules outside a repository don't apply inside it.
+		{name: "/repo/a", expected: false},
+		{name: "/b", expected: true},
+		// The ignore files come before the exclude file.
+		{name: "/repo/c", expected: true},
+		// A later ignore file in the same directory takes precedence.
+		{name: "/repo/d", expected: false},
+		// The closest directory takes precedence.
+		{name: "/repo/sub/e", expected: false},
+		{name: "/repo/f", expected: true},
+		// Global rules are relative to the repository.
+		{name: "/repo/g", expected: true},
+		{name: "/g", expected: true},
+		{name: "/h", expected: false},
 	}
 
 	for _, c := range testCases {
-		matcher := ic.Get(c.input)
+		assert.Equal(t, c.expected, ic.Ignored(AddData{Name: c.name}), c.name)
+	}
+}
 
-		if c.expected == "" {
-			require.Nil(t, matcher)
-			continue
-		}
+func TestIgnoreCache_isIgnoreFile(t *testing.T) {
+	ic := newIgnoreCache(IgnoreSources{}, shared.Logger())
+	assert.True(t, ic.isIgnoreFile("/foo/.gitignore"))
+	assert.False(t, ic.isIgnoreFile("/foo/.ignore"))
+
+	ic = newIgnoreCache(IgnoreSources{Files: []string{".ignore", ".fdignore"}}, shared.Logger())
+	assert.False(t, ic.isIgnoreFile("/foo/.gitignore"))
+	assert.True(t, ic.isIgnoreFile("/foo/.ignore"))
+	assert.True(t, ic.isIgnoreFile("/foo/.fdignore"))
+}
+
+func TestIgnoreCache_Lifecycle(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "ignore-cache-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
 
-		require.NotNil(t, matcher)
-		fake := matcher.(fakeMatcher)
-		assert.Equal(t, fake.name, c.expected)
+	root := filepath.Join(tmp, ".gitignore")
+	nested := filepath.Join(tmp, "sub", ".gitignore")
+	require.NoError(t, os.Mkdir(filepath.Join(tmp, "sub"), 0755))
+	require.NoError(t, os.WriteFile(root, []byte("*.log\n"), 0644))
+	require.NoError(t, os.WriteFile(nested, []byte("*.tmp\n"), 0644))
 
+	ignored := func(ic *IgnoreCache, path ...string) bool {
+		return ic.Ignored(AddData{Name: filepath.Join(append([]string{tmp}, path...)...)})
 	}
+
+	ic := newIgnoreCache(IgnoreSources{}, shared.Logger())
+	require.NoError(t, ic.Add(root))
+	require.NoError(t, ic.Add(nested))
+	assert.True(t, ignored(ic, "debug.log"))
+	assert.True(t, ignored(ic, "sub", "debug.log"))
+	assert.True(t, ignored(ic, "sub", "cache.tmp"))
+
+	// An edit only takes effect once the file is reloaded.
+	require.NoError(t, os.WriteFile(root, []byte("*.out\n"), 0644))
+	assert.True(t, ignored(ic, "debug.log"))
+	require.NoError(t, ic.Reload(root))
+	assert.False(t, ignored(ic, "debug.log"))
+	assert.True(t, ignored(ic, "main.out"))
+	assert.True(t, ignored(ic, "sub", "main.out"))
+
+	// Removing the parent leaves the nested rules.
+	ic.Remove(root)
+	assert.False(t, ignored(ic, "main.out"))
+	assert.True(t, ignored(ic, "sub", "cache.tmp"))
+
+	// A file which can't be read any more stops applying.
+	require.NoError(t, os.Remove(nested))
+	assert.Error(t, ic.Reload(nested))
+	assert.False(t, ignored(ic, "sub", "cache.tmp"))
+	assert.Empty(t, ic.cache)
+
+	require.NoError(t, os.WriteFile(nested, []byte("*.tmp\n"), 0644))
+	require.NoError(t, ic.Add(root))
+	require.NoError(t, ic.Add(nested))
+
+	// A clone can be changed without changing what it was cloned from.
+	clone := ic.clone()
+	clone.Remove(nested)
+	assert.True(t, ignored(ic, "sub", "cache.tmp"))
+	assert.False(t, ignored(clone, "sub", "cache.tmp"))
+
+	ic.removeDir(tmp)
+	assert.Empty(t, ic.cache)
 }
 
-func TestIgnoreCache_findSuperior(t *testing.T) {
-	type testCase struct {
-		levels   []string
-		input    string
-		expected []string
+// TestIgnoreCache_CheckIgnore compares the IgnoreCache with git check-ignore,
+// over nested .gitignore files, .git/info/exclude and a global excludes file.
+func TestIgnoreCache_CheckIgnore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
 	}
 
-	testCases := []testCase{
-		{
-			levels:   []string{"/", "/foo", "/foo/bar"},
-			input:    "/foo/bar/baz",
-			expected: []string{"/", "/foo", "/foo/bar"},
-		},
+	tmp, err := os.MkdirTemp("", "ignore-check-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	repo := filepath.Join(tmp, "repo")
+	global := filepath.Join(tmp, "global-ignore")
+
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-c", "core.excludesFile=" + global}, args...)...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+tmp, "XDG_CONFIG_HOME="+tmp)
+		out, err := cmd.Output()
+		return string(out), err
 	}
 
-	for _, c := range testCases {
-		ic := buildFakeIC(c.levels...)
-
-		res := ic.findSuperior(c.input)
-		assert.Equal(t, c.expected, res)
+	require.NoError(t, os.MkdirAll(repo, 0755))
+	_, err = git("init", "-q")
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"global-ignore":               "*.swp\n.DS_Store\nbuild/\n",
+		"repo/.git/info/exclude":      "/local\n*.orig\n",
+		"repo/.gitignore":             "*.log\n!keep.log\n/out\ndocs/*.html\n**/cache/\nvendor/**/*.pb.go\n[Tt]emp*\nbuild/\n!src/build/\n",
+		"repo/src/.gitignore":         "*.o\n!important.o\ngenerated\n\\#notes\n",
+		"repo/src/lib/.gitignore":     "!*.log\n/only-here\n",
+		"repo/src/lib/nested/.ignore": "*.txt\n",
+	}
+	for file, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmp, file)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(tmp, file), []byte(contents), 0644))
 	}
-}
 
-func buildFakeIC(paths ...string) *IgnoreCache {
-	ic := &IgnoreCache{
-		cache: map[string]gitignore.IgnoreMatcher{},
+	paths := []string{
+		"a.log", "keep.log", "out/x", "src/out", "docs/a.html", "docs/sub/a.html",
+		"x/cache/y", "cache/z", "vendor/a/b/c.pb.go", "vendor/c.pb.go", "Temp.txt", "temp/a",
+		"build/a", "src/build/a", "src/build.o", "src/important.o", "src/generated",
+		"src/a/generated", "src/#notes", "src/lib/a.log", "src/lib/only-here", "src/only-here",
+		"local", "src/local", "a.orig", "src/a.orig", "a.swp", "src/.DS_Store",
+		"src/lib/nested/a.txt", "src/lib/a.txt", "plain.go", "src/plain.go",
+	}
+	for _, name := range paths {
+		full := filepath.Join(repo, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		if _, err := os.Stat(full); os.IsNotExist(err) {
+			require.NoError(t, os.WriteFile(full, nil, 0644))
+		}
 	}
 
-	for _, d := range paths {
-		ic.cache[d] = fakeMatcher{name: d}
+	out, err := git(append([]string{"check-ignore", "--no-index"}, paths...)...)
+	if err != nil && out == "" {
+		// Exit status 1 means nothing is ignored, which would make this test
+		// meaningless.
+		require.NoError(t, err)
+	}
+	expected := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		expected[line] = true
 	}
 
-	return ic
+	ic := newIgnoreCache(IgnoreSources{Exclude: true, ExcludesFile: global}, shared.Logger())
+	ic.load([]string{
+		filepath.Join(repo, ".gitignore"),
+		filepath.Join(repo, "src", ".gitignore"),
+		filepath.Join(repo, "src", "lib", ".gitignore"),
+	}, []string{repo})
+
+	var mismatches bytes.Buffer
+	for _, name := range paths {
+		data := AddData{Name: filepath.Join(repo, name)}
+		if got := ic.IgnoredPath(data); got != expected[name] {
+			mismatches.WriteString(name + "\n")
+		}
+	}
+	assert.Empty(t, mismatches.String(), "paths where git check-ignore disagrees")
 }
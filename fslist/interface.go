@@ -1,17 +1,85 @@
 package fslist
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrNotIndexed is returned by Move when nothing is indexed at from.
+var ErrNotIndexed = errors.New("not indexed")
+
 type FSList interface {
-	Add(AddData) error
+	Writer
+
 	Close() error
-	Delete(AddData) error
+	// Count counts what Fetch would return for the ReadOptions, ignoring
+	// Limit.
+	Count(ReadOptions) (Counts, error)
 	Fetch(ReadOptions) <-chan AddData
 	Flush() error
+	// Len is the number of entries held, the sum of Totals.
 	Len() int
+	// NewBatch starts a Batch of changes.
+	NewBatch() Batch
 	Pending() bool
+	// Seq is the sequence number of the latest change. It goes up whenever
+	// a batch with changes in it is committed, so a list fetched when it was
+	// the same is still up to date. Committing a batch and bumping seq
+	// happen together, apart from taking a snapshot, so a Reader's Seq is
+	// that of exactly the changes it sees.
+	Seq() uint64
+	// Snapshot returns a Reader of the list as it is now. Fetch reads from
+	// one too, as it makes two passes over the range when there is a
+	// CurrentDir, which have to agree.
+	Snapshot() (Reader, error)
+	// Totals counts every entry held, whether ignored or not, without
+	// reading through them.
+	Totals() Counts
+}
+
+// Reader reads an FSList as it was when the Reader was made, however it has
+// changed since.
+type Reader interface {
+	// Count counts what Fetch would return for the ReadOptions, ignoring
+	// Limit.
+	Count(ReadOptions) (Counts, error)
+	Fetch(ReadOptions) <-chan AddData
+	// Seq is the FSList's sequence number when the Reader was made.
+	Seq() uint64
+	// Close releases the Reader. Everything fetched has to have been read
+	// first.
+	Close() error
+}
+
+// Writer makes changes to an FSList, either directly or in a Batch.
+type Writer interface {
+	Add(AddData) error
+	// Delete removes data. If data is a directory everything below it is
+	// removed as well.
+	Delete(AddData) error
+	// Move renames from to to. If from is a directory everything below it is
+	// moved as well. If nothing is indexed at from it returns ErrNotIndexed
+	// without changing anything.
+	Move(from, to AddData) error
+}
+
+// Batch collects changes and applies them together, so readers see either
+// none or all of them. Changes in a batch see the ones made before them.
+type Batch interface {
+	Writer
+
+	// Commit applies every change. The batch can't be used afterwards.
+	Commit() error
+	// Close throws the batch away without applying it.
+	Close() error
+}
+
+// Counts is a number of files and directories.
+type Counts struct {
+	Files int
+	Dirs  int
 }
 
 type ReadOptions struct {
@@ -20,6 +88,60 @@ type ReadOptions struct {
 	FilesOnly  bool
 	Prefix     string
 	CurrentDir string
+	// NoIgnore returns entries matched by ignore files too.
+	NoIgnore bool
+	// NoGlobalIgnore returns the entries only ignored by the global excludes
+	// file, see IgnoreSources.ExcludesFile, while still leaving out the ones
+	// ignored by anything else.
+	NoGlobalIgnore bool
+	// NoHidden leaves out hidden entries, those with a name, or a directory
+	// below Root or Prefix they are in, starting with '.'.
+	NoHidden bool
+	// Root is the directory the index is of, so a root which is itself
+	// below a hidden directory doesn't hide everything.
+	Root string
+	// Query only returns entries with every whitespace separated term of it
+	// in their path. Paths and terms are split into words at anything other
+	// than a letter or digit, and a term's words have to follow each other,
+	// with the last matching the start of a word. So "cache go" matches
+	// fscache/cache.go, as does "cach". The sql mode ranks the entries
+	// matching it by relevance when sqlite has FTS5.
+	Query string
+}
+
+// skipIgnored reports whether an entry with the given Ignored and
+// IgnoredGlobally flags is left out. Everything below a directory which is
+// left out is too.
+func (opts ReadOptions) skipIgnored(ignored, globally bool) bool {
+	switch {
+	case opts.NoIgnore:
+		return false
+	case opts.NoGlobalIgnore:
+		return ignored && !globally
+	default:
+		return ignored
+	}
+}
+
+// skipHidden reports whether the entry stored under key is left out by
+// NoHidden. Only the part of key below hiddenBase counts.
+func (opts ReadOptions) skipHidden(key []byte) bool {
+	if !opts.NoHidden {
+		return false
+	}
+
+	rest := bytes.TrimPrefix(key, []byte(opts.hiddenBase()))
+	return bytes.HasPrefix(rest, []byte(".")) || bytes.Contains(rest, []byte("/."))
+}
+
+// hiddenBase is whichever of Root and Prefix is deeper, which every key read
+// starts with.
+func (opts ReadOptions) hiddenBase() string {
+	if len(opts.Root) > len(opts.Prefix) {
+		return opts.Root
+	}
+
+	return opts.Prefix
 }
 
 type Mode = string
@@ -27,28 +149,48 @@ type Mode = string
 const (
 	ModeSQL    Mode = "sql"
 	ModePebble Mode = "pebble"
+	ModeMemory Mode = "memory"
 )
 
-func Open(mode Mode) (FSList, error) {
-	// do a thing here
+// Options configure an FSList.
+type Options struct {
+	// Ignore is where the rules for which entries are ignored are read
+	// from.
+	Ignore IgnoreSources
+}
+
+// Open opens the persistent FSList for mode stored in location, creating it if
+// it doesn't exist yet.
+func Open(mode Mode, location string, opts Options) (FSList, error) {
 	switch mode {
 	case ModeSQL:
-		return OpenSQL()
+		return OpenSQL(location, opts)
 	case ModePebble:
-		return OpenPebble()
+		return OpenPebble(location, opts)
+	case ModeMemory:
+		return nil, fmt.Errorf("%v mode can't be kept between runs", mode)
 	}
 
 	return nil, fmt.Errorf("Unknown mode: %v", mode)
 }
 
-func New(mode Mode) (FSList, error) {
-	// do a thing here
+// New creates an empty FSList for mode, which is thrown away when it is
+// closed.
+func New(mode Mode, opts Options) (FSList, error) {
 	switch mode {
 	case ModeSQL:
-		return NewSQL()
+		return NewSQL(opts)
 	case ModePebble:
-		return NewPebble()
+		return NewPebble(opts)
+	case ModeMemory:
+		return NewMemory(opts)
 	}
 
 	return nil, fmt.Errorf("Unknown mode: %v", mode)
 }
+
+// firstSeq is the sequence number of a newly opened FSList. It is taken from
+// the clock, so sequence numbers keep going up across restarts.
+func firstSeq() uint64 {
+	return uint64(time.Now().UnixNano())
+}
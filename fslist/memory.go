@@ -0,0 +1,437 @@
+package fslist
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/rs/zerolog"
+)
+
+var _ FSList = &MemoryList{}
+
+// MemoryList keeps everything in a radix tree in memory, for small roots and
+// tests. It is keyed the same way as a PebbleList, so it is read in the same
+// order.
+//
+// The tree is never changed in place. Readers work from the root as it was
+// when they started, and a committed batch swaps in a new one.
+type MemoryList struct {
+	// mu guards root, seq and ignoreCache. Commits hold it while swapping
+	// root, so they apply one at a time.
+	mu          sync.RWMutex
+	root        *radixNode
+	seq         uint64
+	ignoreCache *IgnoreCache
+
+	// files and dirs count the entries in root. They are only written when
+	// a batch is committed, and read atomically.
+	files int64
+	dirs  int64
+
+	logger *zerolog.Logger
+}
+
+// NewMemory creates an empty MemoryList.
+func NewMemory(opts Options) (FSList, error) {
+	logger := shared.Logger().With().Str("module", "memory").Logger()
+
+	return &MemoryList{
+		root:        &radixNode{},
+		seq:         firstSeq(),
+		ignoreCache: newIgnoreCache(opts.Ignore, &logger),
+		logger:      &logger,
+	}, nil
+}
+
+// snapshot returns the current root, which won't change under the caller,
+// and its seq.
+func (s *MemoryList) snapshot() (*radixNode, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.root, s.seq
+}
+
+func (s *MemoryList) Seq() uint64 {
+	_, seq := s.snapshot()
+	return seq
+}
+
+func (s *MemoryList) Snapshot() (Reader, error) {
+	root, seq := s.snapshot()
+	return &memoryReader{list: s, root: root, seq: seq}, nil
+}
+
+func (s *MemoryList) Pending() bool {
+	return false
+}
+
+func (s *MemoryList) NewBatch() Batch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &memoryBatch{
+		list: s,
+		base: s.root,
+		tree: s.newTree(s.root),
+	}
+}
+
+// newTree starts a new version of the tree from root, along with the ignore
+// rules. The caller has to hold mu.
+func (s *MemoryList) newTree(root *radixNode) memoryTree {
+	return memoryTree{root: root, ignores: batchIgnores{IgnoreCache: s.ignoreCache}, logger: s.logger}
+}
+
+func (s *MemoryList) Add(data AddData) error {
+	return s.apply(func(b Batch) error { return b.Add(data) })
+}
+
+// Delete removes data. If data is a directory everything below it is removed
+// as well.
+func (s *MemoryList) Delete(data AddData) error {
+	return s.apply(func(b Batch) error { return b.Delete(data) })
+}
+
+func (s *MemoryList) Move(from, to AddData) error {
+	return s.apply(func(b Batch) error { return b.Move(from, to) })
+}
+
+// apply runs fn in a batch of its own.
+func (s *MemoryList) apply(fn func(Batch) error) error {
+	b := s.NewBatch()
+	if err := fn(b); err != nil {
+		b.Close()
+		return err
+	}
+
+	return b.Commit()
+}
+
+func (s *MemoryList) Len() int {
+	totals := s.Totals()
+	return totals.Files + totals.Dirs
+}
+
+func (s *MemoryList) Totals() Counts {
+	return Counts{
+		Files: int(atomic.LoadInt64(&s.files)),
+		Dirs:  int(atomic.LoadInt64(&s.dirs)),
+	}
+}
+
+func (s *MemoryList) Count(opts ReadOptions) (Counts, error) {
+	root, _ := s.snapshot()
+	fetcher, _ := s.newMemoryFetcher(root, opts)
+	return fetcher.Count()
+}
+
+func (s *MemoryList) newMemoryFetcher(root *radixNode, opts ReadOptions) (*memoryFetcher, <-chan AddData) {
+	ch := make(chan AddData, 1)
+
+	l := s.logger.With().Str("module", "memoryFetcher").Logger()
+	return &memoryFetcher{
+		root:   root,
+		logger: &l,
+		ch:     ch,
+		opts:   opts,
+		query:  parseQuery(opts.Query),
+	}, ch
+}
+
+func (s *MemoryList) Fetch(opts ReadOptions) <-chan AddData {
+	root, _ := s.snapshot()
+	fetcher, ch := s.newMemoryFetcher(root, opts)
+	go fetcher.Fetch()
+
+	return ch
+}
+
+// memoryReader reads a root of the tree, which is never changed.
+type memoryReader struct {
+	list *MemoryList
+	root *radixNode
+	seq  uint64
+}
+
+func (r *memoryReader) Count(opts ReadOptions) (Counts, error) {
+	fetcher, _ := r.list.newMemoryFetcher(r.root, opts)
+	return fetcher.Count()
+}
+
+func (r *memoryReader) Fetch(opts ReadOptions) <-chan AddData {
+	fetcher, ch := r.list.newMemoryFetcher(r.root, opts)
+	go fetcher.Fetch()
+
+	return ch
+}
+
+func (r *memoryReader) Seq() uint64 {
+	return r.seq
+}
+
+func (r *memoryReader) Close() error {
+	// NOOP as the tree is garbage collected.
+	return nil
+}
+
+func (s *MemoryList) Flush() error {
+	// NOOP as there is nothing to write out.
+	return nil
+}
+
+func (s *MemoryList) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.root = &radixNode{}
+	atomic.StoreInt64(&s.files, 0)
+	atomic.StoreInt64(&s.dirs, 0)
+	return nil
+}
+
+var _ Batch = &memoryBatch{}
+
+// memoryBatch makes its changes to its own version of the tree. Each change is
+// also kept, so it can be made again if another batch is committed first.
+type memoryBatch struct {
+	list *MemoryList
+	base *radixNode
+	tree memoryTree
+	ops  []func(*memoryTree)
+}
+
+func (b *memoryBatch) do(op func(*memoryTree)) error {
+	b.ops = append(b.ops, op)
+	op(&b.tree)
+	return nil
+}
+
+func (b *memoryBatch) Add(data AddData) error {
+	b.list.logger.Trace().Object("data", data).Msg("adding")
+	return b.do(func(t *memoryTree) { t.add(data) })
+}
+
+func (b *memoryBatch) Delete(data AddData) error {
+	b.list.logger.Trace().Object("data", data).Msg("deleting")
+	return b.do(func(t *memoryTree) { t.delete(data) })
+}
+
+func (b *memoryBatch) Move(from, to AddData) error {
+	b.list.logger.Trace().Object("from", from).Object("to", to).Msg("moving")
+	if !b.tree.has(from) {
+		return ErrNotIndexed
+	}
+	return b.do(func(t *memoryTree) { t.move(from, to) })
+}
+
+func (b *memoryBatch) Commit() error {
+	b.list.mu.Lock()
+
+	tree := b.tree
+	if b.list.root != b.base {
+		// Another batch was committed since this one started, so the
+		// changes are made again on top of it.
+		tree = b.list.newTree(b.list.root)
+		for _, op := range b.ops {
+			op(&tree)
+		}
+	}
+
+	b.list.root = tree.root
+	if tree.ignores.changed {
+		b.list.ignoreCache = tree.ignores.IgnoreCache
+	}
+	if len(b.ops) > 0 {
+		b.list.seq++
+	}
+	atomic.AddInt64(&b.list.files, tree.files)
+	atomic.AddInt64(&b.list.dirs, tree.dirs)
+	b.list.mu.Unlock()
+
+	b.ops = nil
+	return nil
+}
+
+func (b *memoryBatch) Close() error {
+	// NOOP as nothing is applied before Commit.
+	return nil
+}
+
+// memoryTree is a version of the tree being changed, along with how the
+// counters and ignore rules will have to change with it. As with a
+// PebbleList, ignore files are loaded as they are added, so the entries they
+// apply to are updated along with them.
+type memoryTree struct {
+	root    *radixNode
+	files   int64
+	dirs    int64
+	ignores batchIgnores
+
+	logger *zerolog.Logger
+}
+
+func (t *memoryTree) add(data AddData) {
+	key := string(data.pebbleKey())
+	t.ignores.setIgnored(&data)
+
+	var replaced bool
+	t.root, replaced = t.root.insert(key, &data)
+	if !replaced {
+		t.adjust(data.IsDir, 1)
+	}
+
+	if t.ignores.isIgnoreFile(data.Name) {
+		t.ignores.change().tryAdd(data.Name)
+		t.updateIgnored(filepath.Dir(data.Name))
+	}
+
+	// The rules from outside a repository stop applying below its root.
+	if dir, ok := repoRoot(data.Name); ok && t.ignores.change().addRepo(dir) {
+		t.updateIgnored(dir)
+	}
+}
+
+func (t *memoryTree) delete(data AddData) {
+	key := string(data.pebbleKey())
+	if !data.IsDir {
+		var removed *AddData
+		if t.root, removed = t.root.remove(key); removed != nil {
+			t.adjust(removed.IsDir, -1)
+		}
+
+		if t.ignores.isIgnoreFile(data.Name) {
+			t.ignores.change().Remove(data.Name)
+			t.updateIgnored(filepath.Dir(data.Name))
+		}
+		t.removeRepo(data.Name)
+		return
+	}
+
+	// Directory keys end in '/', so this only covers the directory and its
+	// children, not siblings sharing its name as a prefix.
+	var removed Counts
+	t.root, removed = t.root.removePrefix(key)
+	t.files -= int64(removed.Files)
+	t.dirs -= int64(removed.Dirs)
+	t.ignores.change().removeDir(data.Name)
+	t.removeRepo(data.Name)
+}
+
+// removeRepo drops the repository when name is its .git, updating what is
+// below its root.
+func (t *memoryTree) removeRepo(name string) {
+	if dir, ok := repoRoot(name); ok && t.ignores.change().removeRepo(dir) {
+		t.updateIgnored(dir)
+	}
+}
+
+// has reports whether data, or anything below it for a directory, is held.
+func (t *memoryTree) has(data AddData) bool {
+	key := string(data.pebbleKey())
+	if !data.IsDir {
+		return t.root.get(key) != nil
+	}
+
+	found := false
+	t.root.walk("", key, string(calcUpperBound(key)), func(string, *AddData) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+func (t *memoryTree) move(from, to AddData) {
+	fromKey := string(from.pebbleKey())
+
+	type move struct {
+		from, to string
+		value    AddData
+	}
+
+	// The entries are collected before any are moved, as with a PebbleList.
+	moves := []move{}
+	collect := func(key string, value *AddData) bool {
+		// Only the name changes, the rest is carried over.
+		name, isDir := pebbleKeyName([]byte(key))
+		data := *value
+		data.Name = to.Name + strings.TrimPrefix(name, from.Name)
+		data.IsDir = isDir
+		moves = append(moves, move{from: key, to: string(data.pebbleKey()), value: data})
+		return true
+	}
+	if from.IsDir {
+		t.root.walk("", fromKey, string(calcUpperBound(fromKey)), collect)
+	} else if value := t.root.get(fromKey); value != nil {
+		collect(fromKey, value)
+	}
+
+	if len(moves) == 0 {
+		// Only when replayed onto a tree where from has since gone.
+		return
+	}
+
+	// The rules are brought up to date first, as ignore files apply to
+	// the entries moved along with them.
+	moved := []AddData{}
+	for _, m := range moves {
+		moved = append(moved, m.value)
+	}
+	changed := t.ignores.change().move(from, to, moved)
+
+	walker := newFlagWalker(t.ignores.IgnoreCache, to.Name)
+	for _, m := range moves {
+		// Moved entries only change the counters when they land on top of
+		// something that is already there.
+		inSource := m.to == fromKey || (from.IsDir && strings.HasPrefix(m.to, fromKey))
+		if !inSource && t.root.get(m.to) != nil {
+			t.adjust(m.value.IsDir, -1)
+		}
+
+		t.root, _ = t.root.remove(m.from)
+		value := m.value
+		value.Ignored, value.IgnoredGlobally = walker.flags([]byte(m.to))
+		t.root, _ = t.root.insert(m.to, &value)
+	}
+
+	for _, dir := range changed {
+		t.updateIgnored(dir)
+	}
+}
+
+// updateIgnored works out again whether dir, and everything below it, is
+// ignored, after the rules which apply to it have changed.
+func (t *memoryTree) updateIgnored(dir string) {
+	lower := string(AddData{Name: dir, IsDir: true}.pebbleKey())
+	walker := newFlagWalker(t.ignores.IgnoreCache, dir)
+
+	// The tree can't be changed while it is walked, so the updates are
+	// collected first.
+	updates := map[string]AddData{}
+	t.root.walk("", lower, string(calcUpperBound(lower)), func(key string, value *AddData) bool {
+		ignored, globally := walker.flags([]byte(key))
+		if ignored != value.Ignored || globally != value.IgnoredGlobally {
+			data := *value
+			data.Ignored, data.IgnoredGlobally = ignored, globally
+			updates[key] = data
+		}
+		return true
+	})
+
+	for key, data := range updates {
+		data := data
+		t.root, _ = t.root.insert(key, &data)
+	}
+}
+
+// adjust changes the counter for a file or directory by delta.
+func (t *memoryTree) adjust(isDir bool, delta int64) {
+	if isDir {
+		t.dirs += delta
+	} else {
+		t.files += delta
+	}
+}
@@ -0,0 +1,121 @@
+package fslist
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// memoryFetcher reads a MemoryList in the same order, and with the same
+// filters, as a pebbleFetcher reads a PebbleList.
+type memoryFetcher struct {
+	root   *radixNode
+	count  int
+	ch     chan<- AddData
+	opts   ReadOptions
+	query  query
+	logger *zerolog.Logger
+}
+
+func (mf *memoryFetcher) Fetch() (int, error) {
+	defer close(mf.ch)
+
+	if mf.opts.Prefix != "" {
+		return mf.fetchRangeWithPrefix()
+	}
+
+	return mf.fetchRange("", ""), nil
+}
+
+// fetchRangeWithPrefix returns the items from the current working directory
+// onwards first, then the rest of the prefix, see
+// pebbleFetcher.fetchRangeWithPrefix.
+func (mf *memoryFetcher) fetchRangeWithPrefix() (int, error) {
+	lowerBound := mf.opts.Prefix
+	middleBound := lowerBound
+	upperBound := string(calcUpperBound(mf.opts.Prefix))
+
+	if mf.opts.CurrentDir != "" && mf.opts.CurrentDir != mf.opts.Prefix {
+		middleBound = mf.opts.CurrentDir
+	}
+
+	mf.count = mf.fetchRange(middleBound, upperBound)
+	return mf.fetchRange(lowerBound, middleBound), nil
+}
+
+func (mf *memoryFetcher) fetchRange(lower, upper string) int {
+	mf.logger.Debug().Dict("bounds",
+		zerolog.Dict().
+			Str("lower", lower).
+			Str("upper", upper),
+	).Msg("Doing a fetchRange")
+
+	mf.scan(lower, upper, func(data AddData) bool {
+		if mf.opts.Limit > 0 && mf.count >= mf.opts.Limit {
+			return false
+		}
+
+		mf.ch <- data
+		mf.count++
+		return true
+	})
+
+	return mf.count
+}
+
+// Count counts the entries Fetch would send, ignoring Limit.
+func (mf *memoryFetcher) Count() (Counts, error) {
+	var lower, upper string
+	if mf.opts.Prefix != "" {
+		lower = mf.opts.Prefix
+		upper = string(calcUpperBound(mf.opts.Prefix))
+	}
+
+	counts := Counts{}
+	mf.scan(lower, upper, func(data AddData) bool {
+		if data.IsDir {
+			counts.Dirs++
+		} else {
+			counts.Files++
+		}
+		return true
+	})
+
+	return counts, nil
+}
+
+// scan calls fn with every entry from lower to upper which passes the
+// ReadOptions filters. Everything below an ignored directory is ignored as
+// well, so is skipped without being looked at. fn returns false to stop early.
+func (mf *memoryFetcher) scan(lower, upper string, fn func(AddData) bool) {
+	// skip is the key of the ignored directory being skipped, if any.
+	skip := ""
+	mf.root.walk("", lower, upper, func(key string, value *AddData) bool {
+		if skip != "" && strings.HasPrefix(key, skip) {
+			return true
+		}
+
+		data := *value
+		data.Name, data.IsDir = pebbleKeyName([]byte(key))
+		if mf.opts.skipIgnored(data.Ignored, data.IgnoredGlobally) || mf.opts.skipHidden([]byte(key)) {
+			mf.logger.Trace().Str("file", data.Name).Msg("skipping")
+			if data.IsDir {
+				skip = key
+			}
+			return true
+		}
+
+		if mf.opts.DirsOnly && !data.IsDir {
+			mf.logger.Trace().Str("file", data.Name).Msg("skipping non-dir")
+			return true
+		} else if mf.opts.FilesOnly && data.IsDir {
+			mf.logger.Trace().Str("file", data.Name).Msg("Skipping non-file")
+			return true
+		} else if !mf.query.matches(data.Name) {
+			mf.logger.Trace().Str("file", data.Name).Msg("skipping non-match")
+			return true
+		}
+
+		return fn(data)
+	})
+}
@@ -0,0 +1,22 @@
+package fslist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryList_Close(t *testing.T) {
+	db, err := NewMemory(Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Add(AddData{Name: "/a", IsDir: true}))
+	require.NoError(t, db.Add(AddData{Name: "/a/b"}))
+	assert.Equal(t, Counts{Files: 1, Dirs: 1}, db.Totals())
+
+	// Close leaves the list empty, counters included.
+	require.NoError(t, db.Close())
+	assert.Equal(t, Counts{}, db.Totals())
+	assert.Equal(t, 0, db.Len())
+}
@@ -1,10 +1,10 @@
 package fslist
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/keyneston/fscache/internal/shared"
@@ -21,36 +21,55 @@ var _ FSList = &PebbleList{}
 type PebbleList struct {
 	db          *pebble.DB
 	location    string
+	ephemeral   bool
 	ignoreCache *IgnoreCache
 
-	logger *zerolog.Logger
-}
+	// files and dirs count the entries in db. They are only written when
+	// a batch is committed, and read atomically.
+	files int64
+	dirs  int64
 
-func NewPebble() (FSList, error) {
-	return openPebble()
-}
+	// seqMu is held to apply a batch, bump seq and swap in its ignore
+	// rules, and read to take a pebble snapshot or start a batch.
+	seqMu sync.RWMutex
+	seq   uint64
 
-func OpenPebble() (FSList, error) {
-	return openPebble()
+	logger *zerolog.Logger
 }
 
-func openPebble() (FSList, error) {
+// NewPebble creates an empty PebbleList in a temporary directory, which is
+// removed again when it is closed.
+func NewPebble(opts Options) (FSList, error) {
 	location, err := os.MkdirTemp("", "fscache-pebble-db-*")
 	if err != nil {
 		return nil, err
 	}
 
+	return openPebble(location, true, opts)
+}
+
+// OpenPebble opens the PebbleList stored at location, creating it if it
+// doesn't exist yet.
+func OpenPebble(location string, opts Options) (FSList, error) {
+	if err := os.MkdirAll(location, 0700); err != nil {
+		return nil, err
+	}
+
+	return openPebble(location, false, opts)
+}
+
+func openPebble(location string, ephemeral bool, opts Options) (FSList, error) {
 	logger := shared.Logger().With().Str("database", location).Str("mode", "pebble").Logger()
-	logger.Debug().Msg("opening pebble database")
+	logger.Debug().Bool("ephemeral", ephemeral).Msg("opening pebble database")
 
 	if location == "" {
 		return nil, fmt.Errorf("Must supply a location for the database")
 	}
 
-	// location should be a socket, then we create the DB in a tmp dir each time.
-
 	db, err := pebble.Open(location, &pebble.Options{
-		DisableWAL: true, // Database is wiped away at start, so WAL is not needed.
+		// An ephemeral database is thrown away on close, so there is never
+		// anything for the WAL to recover.
+		DisableWAL: ephemeral,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Error creating PebbleList: %w", err)
@@ -58,78 +77,203 @@ func openPebble() (FSList, error) {
 
 	s := &PebbleList{
 		db:          db,
-		ignoreCache: &IgnoreCache{},
+		ignoreCache: newIgnoreCache(opts.Ignore, &logger),
 		location:    location,
+		ephemeral:   ephemeral,
+		seq:         firstSeq(),
 		logger:      &logger,
 	}
 
+	// Only keys are read to load, so it works with any schema version, and
+	// the migrations can use the ignore cache.
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.refreshIgnored(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return s, nil
 }
 
-func (s *PebbleList) Close() error {
-	return s.db.Close()
-}
+// load fills the counters and the ignore cache from what is already in the
+// database.
+func (s *PebbleList) load() error {
+	files, repos := []string{}, []string{}
+
+	iter := s.db.NewIter(pathBounds())
+	for iter.First(); iter.Valid(); iter.Next() {
+		s.adjust(iter.Key(), 1)
+
+		name, isDir := pebbleKeyName(iter.Key())
+		if dir, ok := repoRoot(name); ok {
+			repos = append(repos, dir)
+		} else if !isDir && s.ignoreCache.isIgnoreFile(name) {
+			files = append(files, name)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
 
-func (s *PebbleList) init() error {
+	s.ignoreCache.load(files, repos)
 	return nil
 }
 
+func (s *PebbleList) Close() error {
+	err := s.db.Close()
+
+	if s.ephemeral {
+		if rmErr := os.RemoveAll(s.location); err == nil {
+			err = rmErr
+		}
+	}
+
+	return err
+}
+
 func (s *PebbleList) Pending() bool {
 	return false
 }
 
-func (s *PebbleList) Add(data AddData) error {
-	s.logger.Trace().Object("data", data).Msg("adding")
+func (s *PebbleList) NewBatch() Batch {
+	s.seqMu.RLock()
+	defer s.seqMu.RUnlock()
 
-	encoded, err := json.Marshal(data)
-	if err != nil {
-		return err
+	return &pebbleBatch{
+		list:    s,
+		batch:   s.db.NewIndexedBatch(),
+		ignores: batchIgnores{IgnoreCache: s.ignoreCache},
 	}
+}
+
+func (s *PebbleList) Add(data AddData) error {
+	return s.apply(func(b Batch) error { return b.Add(data) })
+}
 
-	if err := s.db.Set(data.pebbleKey(), encoded, pebble.NoSync); err != nil {
+// Delete removes data. If data is a directory everything below it is removed
+// as well.
+func (s *PebbleList) Delete(data AddData) error {
+	return s.apply(func(b Batch) error { return b.Delete(data) })
+}
+
+func (s *PebbleList) Move(from, to AddData) error {
+	return s.apply(func(b Batch) error { return b.Move(from, to) })
+}
+
+// apply runs fn in a batch of its own.
+func (s *PebbleList) apply(fn func(Batch) error) error {
+	b := s.NewBatch()
+	if err := fn(b); err != nil {
+		b.Close()
 		return err
 	}
 
-	if filepath.Base(data.Name) == ".gitignore" {
-		if err := s.ignoreCache.Add(string(data.pebbleKey())); err != nil {
-			return err
-		}
+	return b.Commit()
+}
+
+func (s *PebbleList) Len() int {
+	totals := s.Totals()
+	return totals.Files + totals.Dirs
+}
+
+func (s *PebbleList) Totals() Counts {
+	return Counts{
+		Files: int(atomic.LoadInt64(&s.files)),
+		Dirs:  int(atomic.LoadInt64(&s.dirs)),
 	}
+}
 
-	return nil
+func (s *PebbleList) Count(opts ReadOptions) (Counts, error) {
+	fetcher, _ := s.newPebbleFetcher(s.db, opts)
+	return fetcher.Count()
 }
 
-func (s *PebbleList) Delete(data AddData) error {
-	s.logger.Trace().Object("data", data).Msg("deleting")
-	return s.db.Delete(data.pebbleKey(), pebble.NoSync)
+func (s *PebbleList) Seq() uint64 {
+	return atomic.LoadUint64(&s.seq)
 }
 
-func (s *PebbleList) Len() int {
-	// TODO
-	return 0
+func (s *PebbleList) Snapshot() (Reader, error) {
+	s.seqMu.RLock()
+	defer s.seqMu.RUnlock()
+
+	return &pebbleReader{
+		list:     s,
+		snapshot: s.db.NewSnapshot(),
+		seq:      atomic.LoadUint64(&s.seq),
+	}, nil
 }
 
-func (s *PebbleList) newPebbleFetcher(opts ReadOptions) (*pebbleFetcher, <-chan AddData) {
+// adjust changes the counter for the entry stored under key by delta.
+func (s *PebbleList) adjust(key []byte, delta int64) {
+	if isDirKey(key) {
+		atomic.AddInt64(&s.dirs, delta)
+	} else {
+		atomic.AddInt64(&s.files, delta)
+	}
+}
+
+func (s *PebbleList) newPebbleFetcher(db pebble.Reader, opts ReadOptions) (*pebbleFetcher, <-chan AddData) {
 	ch := make(chan AddData, 1)
 
 	l := s.logger.With().Str("module", "pebbleFetcher").Logger()
 	return &pebbleFetcher{
-		db:          s.db,
-		ignoreCache: s.ignoreCache,
-		logger:      &l,
-		ch:          ch,
-		opts:        opts,
-		count:       0,
+		db:     db,
+		logger: &l,
+		ch:     ch,
+		opts:   opts,
+		query:  parseQuery(opts.Query),
+		count:  0,
 	}, ch
 }
 
+// Fetch reads from a pebble snapshot, closed once the fetch is done.
 func (s *PebbleList) Fetch(opts ReadOptions) <-chan AddData {
-	fetcher, ch := s.newPebbleFetcher(opts)
+	snapshot := s.db.NewSnapshot()
+	fetcher, ch := s.newPebbleFetcher(snapshot, opts)
+	go func() {
+		defer snapshot.Close()
+		fetcher.Fetch()
+	}()
+
+	return ch
+}
+
+// pebbleReader reads from a pebble.Snapshot.
+type pebbleReader struct {
+	list     *PebbleList
+	snapshot *pebble.Snapshot
+	seq      uint64
+}
+
+func (r *pebbleReader) Count(opts ReadOptions) (Counts, error) {
+	fetcher, _ := r.list.newPebbleFetcher(r.snapshot, opts)
+	return fetcher.Count()
+}
+
+func (r *pebbleReader) Fetch(opts ReadOptions) <-chan AddData {
+	fetcher, ch := r.list.newPebbleFetcher(r.snapshot, opts)
 	go fetcher.Fetch()
 
 	return ch
 }
 
+func (r *pebbleReader) Seq() uint64 {
+	return r.seq
+}
+
+func (r *pebbleReader) Close() error {
+	return r.snapshot.Close()
+}
+
 func (s *PebbleList) Flush() error {
 	return s.db.Flush()
 }
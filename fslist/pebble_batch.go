@@ -0,0 +1,283 @@
+package fslist
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+)
+
+var _ Batch = &pebbleBatch{}
+
+// pebbleBatch is an indexed pebble.Batch, so changes can read what the batch
+// has already written. Ignore files are loaded as they are added, so the
+// entries they apply to are updated along with them, but like the counters
+// the list only sees the new rules once the batch is committed.
+type pebbleBatch struct {
+	list    *PebbleList
+	batch   *pebble.Batch
+	ignores batchIgnores
+
+	files  int64
+	dirs   int64
+	closed bool
+}
+
+func (b *pebbleBatch) Add(data AddData) error {
+	b.list.logger.Trace().Object("data", data).Msg("adding")
+
+	key := data.pebbleKey()
+	existed, err := b.exists(key)
+	if err != nil {
+		return err
+	}
+
+	b.ignores.setIgnored(&data)
+	if err := b.batch.Set(key, encodeValue(data), nil); err != nil {
+		return err
+	}
+	if !existed {
+		b.adjust(key, 1)
+	}
+
+	if b.ignores.isIgnoreFile(data.Name) {
+		b.ignores.change().tryAdd(data.Name)
+		return b.updateIgnored(filepath.Dir(data.Name))
+	}
+
+	// The rules from outside a repository stop applying below its root.
+	if dir, ok := repoRoot(data.Name); ok && b.ignores.change().addRepo(dir) {
+		return b.updateIgnored(dir)
+	}
+
+	return nil
+}
+
+func (b *pebbleBatch) Delete(data AddData) error {
+	b.list.logger.Trace().Object("data", data).Msg("deleting")
+
+	key := data.pebbleKey()
+	if !data.IsDir {
+		existed, err := b.exists(key)
+		if err != nil || !existed {
+			return err
+		}
+
+		if err := b.batch.Delete(key, nil); err != nil {
+			return err
+		}
+		b.adjust(key, -1)
+
+		if b.ignores.isIgnoreFile(data.Name) {
+			b.ignores.change().Remove(data.Name)
+			return b.updateIgnored(filepath.Dir(data.Name))
+		}
+		return b.removeRepo(data.Name)
+	}
+
+	// Directory keys end in '/', so this range only covers the directory
+	// and its children, not siblings sharing its name as a prefix.
+	upper := calcUpperBound(string(key))
+
+	// The range is counted first, so the counters can be brought down by
+	// however much it held.
+	iter := b.batch.NewIter(&pebble.IterOptions{LowerBound: key, UpperBound: upper})
+	for iter.First(); iter.Valid(); iter.Next() {
+		b.adjust(iter.Key(), -1)
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	b.ignores.change().removeDir(data.Name)
+	if err := b.batch.DeleteRange(key, upper, nil); err != nil {
+		return err
+	}
+	return b.removeRepo(data.Name)
+}
+
+// removeRepo drops the repository when name is its .git, updating what is
+// below its root.
+func (b *pebbleBatch) removeRepo(name string) error {
+	if dir, ok := repoRoot(name); ok && b.ignores.change().removeRepo(dir) {
+		return b.updateIgnored(dir)
+	}
+	return nil
+}
+
+func (b *pebbleBatch) Move(from, to AddData) error {
+	b.list.logger.Trace().Object("from", from).Object("to", to).Msg("moving")
+
+	fromKey := from.pebbleKey()
+	upper := append(append([]byte{}, fromKey...), 0)
+	if from.IsDir {
+		upper = calcUpperBound(string(fromKey))
+	}
+
+	type move struct {
+		from, to, value []byte
+	}
+
+	// Iterators over a batch see later writes to it, so the moves are
+	// collected before any are made.
+	moves := []move{}
+	iter := b.batch.NewIter(&pebble.IterOptions{
+		LowerBound: fromKey,
+		UpperBound: upper,
+	})
+	for iter.First(); iter.Valid(); iter.Next() {
+		// Only the key changes, as the name isn't part of the value.
+		name, isDir := pebbleKeyName(iter.Key())
+		data := AddData{Name: to.Name + strings.TrimPrefix(name, from.Name), IsDir: isDir}
+
+		moves = append(moves, move{
+			from:  append([]byte{}, iter.Key()...),
+			to:    data.pebbleKey(),
+			value: append([]byte{}, iter.Value()...),
+		})
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	if len(moves) == 0 {
+		return ErrNotIndexed
+	}
+
+	// The rules are brought up to date first, as ignore files apply to
+	// the entries moved along with them.
+	moved := []AddData{}
+	for _, m := range moves {
+		name, isDir := pebbleKeyName(m.to)
+		moved = append(moved, AddData{Name: name, IsDir: isDir})
+	}
+	changed := b.ignores.change().move(from, to, moved)
+
+	walker := newFlagWalker(b.ignores.IgnoreCache, to.Name)
+	for _, m := range moves {
+		// Moved entries only change the counters when they land on top of
+		// something that is already there.
+		if bytes.Compare(m.to, fromKey) < 0 || bytes.Compare(m.to, upper) >= 0 {
+			existed, err := b.exists(m.to)
+			if err != nil {
+				return err
+			}
+			if existed {
+				b.adjust(m.to, -1)
+			}
+		}
+
+		if err := b.batch.Delete(m.from, nil); err != nil {
+			return err
+		}
+		ignored, globally := walker.flags(m.to)
+		if err := b.batch.Set(m.to, setValueIgnored(m.value, ignored, globally), nil); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range changed {
+		if err := b.updateIgnored(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateIgnored works out again whether dir, and everything below it, is
+// ignored, after the rules which apply to it have changed.
+func (b *pebbleBatch) updateIgnored(dir string) error {
+	lower := AddData{Name: dir, IsDir: true}.pebbleKey()
+	walker := newFlagWalker(b.ignores.IgnoreCache, dir)
+
+	type update struct {
+		key, value []byte
+	}
+
+	// As with Move, the updates are collected before any are made.
+	updates := []update{}
+	iter := b.batch.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: calcUpperBound(string(lower)),
+	})
+	for iter.First(); iter.Valid(); iter.Next() {
+		ignored, globally := walker.flags(iter.Key())
+		if wasIgnored, wasGlobally := valueIgnored(iter.Value()); ignored != wasIgnored || globally != wasGlobally {
+			updates = append(updates, update{
+				key:   append([]byte{}, iter.Key()...),
+				value: setValueIgnored(iter.Value(), ignored, globally),
+			})
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if err := b.batch.Set(u.key, u.value, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *pebbleBatch) Commit() error {
+	defer b.Close()
+
+	changed := !b.batch.Empty()
+
+	b.list.seqMu.Lock()
+	err := b.batch.Commit(pebble.NoSync)
+	if err == nil && changed {
+		atomic.AddUint64(&b.list.seq, 1)
+	}
+	if err == nil && b.ignores.changed {
+		b.list.ignoreCache = b.ignores.IgnoreCache
+	}
+	b.list.seqMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&b.list.files, b.files)
+	atomic.AddInt64(&b.list.dirs, b.dirs)
+
+	return nil
+}
+
+// Close discards the batch unless it has been committed. Closing it again is
+// a no-op.
+func (b *pebbleBatch) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	return b.batch.Close()
+}
+
+// adjust changes the counter for the entry stored under key by delta.
+func (b *pebbleBatch) adjust(key []byte, delta int64) {
+	if isDirKey(key) {
+		b.dirs += delta
+	} else {
+		b.files += delta
+	}
+}
+
+func (b *pebbleBatch) exists(key []byte) (bool, error) {
+	_, closer, err := b.batch.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, closer.Close()
+}
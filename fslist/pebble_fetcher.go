@@ -1,19 +1,17 @@
 package fslist
 
 import (
-	"encoding/json"
-
 	"github.com/cockroachdb/pebble"
 	"github.com/rs/zerolog"
 )
 
 type pebbleFetcher struct {
-	db          *pebble.DB
-	ignoreCache *IgnoreCache
-	count       int
-	ch          chan<- AddData
-	opts        ReadOptions
-	logger      *zerolog.Logger
+	db     pebble.Reader
+	count  int
+	ch     chan<- AddData
+	opts   ReadOptions
+	query  query
+	logger *zerolog.Logger
 }
 
 func (pf *pebbleFetcher) Fetch() (int, error) {
@@ -59,72 +57,105 @@ func (pf *pebbleFetcher) fetchRangeWithPrefix() (int, error) {
 // fetchRange does the heavy lifting of actually iterating from lower to upper
 // and sending them onto the channel.
 func (pf *pebbleFetcher) fetchRange(lower, upper []byte) (int, error) {
-	var iterOpts *pebble.IterOptions
-	if len(lower) > 0 || len(upper) > 0 {
-		iterOpts = &pebble.IterOptions{
-			LowerBound: lower,
-			UpperBound: upper,
-		}
-	}
-
 	pf.logger.Debug().Dict("bounds",
 		zerolog.Dict().
 			Bytes("lower", lower).
 			Bytes("upper", upper),
 	).Msg("Doing a fetchRange")
 
-	iter := pf.db.NewIter(iterOpts)
-	defer iter.Close()
-
-	for iter.First(); iter.Valid(); iter.Next() {
+	err := pf.scan(lower, upper, func(key, value []byte) (bool, error) {
 		if pf.opts.Limit > 0 && pf.count >= pf.opts.Limit {
-			return pf.count, nil
+			return false, nil
 		}
 
-		var data AddData
-		if err := json.Unmarshal(iter.Value(), &data); err != nil {
-			return pf.count, err
+		data, err := decodeValue(key, value)
+		if err != nil {
+			return false, err
 		}
-		pf.logger.Trace().Str("file", data.Name).Msg("checking")
 
-		ignore := pf.ignoreCache.Get(data.Name)
-		if ignore != nil && ignore.Match(data.Name, data.IsDir) {
-			pf.logger.Trace().Str("file", data.Name).Msg("skipping")
+		pf.ch <- data
+		pf.count++
+		return true, nil
+	})
 
-			// This requires that the final character be a '/' otherwise the
-			// skip will skip adjacent files:
-			//
-			// Without trailing /
-			//
-			// * file <- ignore this
-			// * file.foo <- don't want to skip this
-			// * file/foo <- want to ignore this
-			//
-			// With trailing /
+	return pf.count, err
+}
+
+// Count counts the entries Fetch would send, ignoring Limit. Values aren't
+// decoded, as keys are enough to tell files and directories apart.
+func (pf *pebbleFetcher) Count() (Counts, error) {
+	var lower, upper []byte
+	if pf.opts.Prefix != "" {
+		lower = []byte(pf.opts.Prefix)
+		upper = calcUpperBound(pf.opts.Prefix)
+	}
+
+	counts := Counts{}
+	err := pf.scan(lower, upper, func(key, _ []byte) (bool, error) {
+		if isDirKey(key) {
+			counts.Dirs++
+		} else {
+			counts.Files++
+		}
+		return true, nil
+	})
+
+	return counts, err
+}
+
+// scan calls fn with every entry from lower to upper which passes the
+// ReadOptions filters. Everything below an ignored directory is ignored as
+// well, so is skipped over entirely. fn returns false to stop early.
+func (pf *pebbleFetcher) scan(lower, upper []byte, fn func(key, value []byte) (bool, error)) error {
+	iterOpts := pathBounds()
+	if len(lower) > 0 || len(upper) > 0 {
+		iterOpts = &pebble.IterOptions{
+			LowerBound: lower,
+			UpperBound: upper,
+		}
+	}
+
+	iter := pf.db.NewIter(iterOpts)
+	defer iter.Close()
+
+	for valid := iter.First(); valid; {
+		key := iter.Key()
+		name, isDir := pebbleKeyName(key)
+		pf.logger.Trace().Str("file", name).Msg("checking")
+
+		if ignored, globally := valueIgnored(iter.Value()); pf.opts.skipIgnored(ignored, globally) || pf.opts.skipHidden(key) {
+			pf.logger.Trace().Str("file", name).Msg("skipping")
+
+			// Directory keys end in '/', so this skips everything below
+			// the directory without skipping siblings that share its name
+			// as a prefix:
 			//
 			// * file.foo <- don't want to skip this
 			// * file/ <- ignore this
 			// * file/foo <- want to ignore this
 			//
-			if data.IsDir {
-				iter.SeekGE(calcUpperBound(string(data.Name)))
+			if isDir {
+				valid = iter.SeekGE(calcUpperBound(string(key)))
+			} else {
+				valid = iter.Next()
 			}
 			continue
 		}
 
-		if pf.opts.DirsOnly && !data.IsDir {
-			pf.logger.Trace().Str("file", data.Name).Msg("skipping non-dir")
-			continue
-		} else if pf.opts.FilesOnly && data.IsDir {
-			pf.logger.Trace().Str("file", data.Name).Msg("Skipping non-file")
-			continue
+		if pf.opts.DirsOnly && !isDir {
+			pf.logger.Trace().Str("file", name).Msg("skipping non-dir")
+		} else if pf.opts.FilesOnly && isDir {
+			pf.logger.Trace().Str("file", name).Msg("Skipping non-file")
+		} else if !pf.query.matches(name) {
+			pf.logger.Trace().Str("file", name).Msg("skipping non-match")
+		} else if more, err := fn(key, iter.Value()); err != nil || !more {
+			return err
 		}
 
-		pf.ch <- data
-		pf.count++
+		valid = iter.Next()
 	}
 
-	return 0, nil
+	return nil
 }
 
 // calcUpperBound takes a string and converts its last character to one greater than it is. e.g. prefix => prefiy. That way it can match all all things that being with prefix but nothing else.
@@ -0,0 +1,212 @@
+package fslist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// schemaVersion is the version of the layout written by this PebbleList. 0 is
+// the JSON values used before the version was recorded.
+const schemaVersion = 2
+
+// schemaVersionKey holds the schema version of the database. Every path
+// starts with '/', so it sorts before all of them and is outside pathBounds.
+var schemaVersionKey = []byte("\x00schema-version")
+
+// ignoreStateKey holds the IgnoreCache state the ignore flags were last worked
+// out with, see refreshIgnored. It is outside pathBounds too.
+var ignoreStateKey = []byte("\x00ignore-state")
+
+// migrations[v] upgrades a database from schema version v to v+1. They may be
+// interrupted, and so have to be safe to run again over their own output.
+var migrations = []func(*PebbleList) error{
+	0: migrateJSONValues,
+	1: (*PebbleList).updateIgnored,
+}
+
+// migrateBatchSize is how many entries a migration rewrites per batch.
+const migrateBatchSize = 10000
+
+// pathBounds limits an iterator to the entries, leaving out metadata such as
+// schemaVersionKey.
+func pathBounds() *pebble.IterOptions {
+	return &pebble.IterOptions{
+		LowerBound: []byte("/"),
+		UpperBound: calcUpperBound("/"),
+	}
+}
+
+// migrate brings the database up to schemaVersion.
+func (s *PebbleList) migrate() error {
+	version, ok, err := s.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		// Either a new database, or one from before the version was
+		// recorded.
+		iter := s.db.NewIter(pathBounds())
+		empty := !iter.First()
+		if err := iter.Close(); err != nil {
+			return err
+		}
+
+		if empty {
+			return s.setSchemaVersion(schemaVersion)
+		}
+	}
+
+	if version > schemaVersion {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", version, schemaVersion)
+	}
+
+	for ; version < schemaVersion; version++ {
+		s.logger.Info().Int("from", version).Int("to", version+1).Msg("migrating database")
+
+		if err := migrations[version](s); err != nil {
+			return fmt.Errorf("error migrating database from schema version %d: %w", version, err)
+		}
+		if err := s.setSchemaVersion(version + 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion returns the recorded schema version, and whether there was
+// one.
+func (s *PebbleList) schemaVersion() (int, bool, error) {
+	value, closer, err := s.db.Get(schemaVersionKey)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer closer.Close()
+
+	version, n := binary.Uvarint(value)
+	if n <= 0 {
+		return 0, false, fmt.Errorf("invalid schema version: %q", value)
+	}
+
+	return int(version), true, nil
+}
+
+func (s *PebbleList) setSchemaVersion(version int) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(version))
+
+	return s.db.Set(schemaVersionKey, buf[:n], s.syncOpts())
+}
+
+// migrateJSONValues rewrites the JSON values of schema version 0 in the
+// binary encoding.
+func migrateJSONValues(s *PebbleList) error {
+	iter := s.db.NewIter(pathBounds())
+	defer iter.Close()
+
+	batch := s.db.NewBatch()
+	for iter.First(); iter.Valid(); iter.Next() {
+		if value := iter.Value(); len(value) > 0 && value[0] == valueVersion {
+			// Already rewritten by an earlier, interrupted, run.
+			continue
+		}
+
+		data, err := decodeJSONValue(iter.Value())
+		if err != nil {
+			batch.Close()
+			return fmt.Errorf("%q: %w", iter.Key(), err)
+		}
+
+		batch.Set(iter.Key(), encodeValue(data), nil)
+
+		if batch.Count() >= migrateBatchSize {
+			if err := batch.Commit(s.syncOpts()); err != nil {
+				batch.Close()
+				return err
+			}
+			batch.Close()
+			batch = s.db.NewBatch()
+		}
+	}
+
+	if err := batch.Commit(s.syncOpts()); err != nil {
+		batch.Close()
+		return err
+	}
+	return batch.Close()
+}
+
+// updateIgnored works out again whether every entry is ignored, setting
+// flagIgnored and flagIgnoredGlobally where they have changed. Schema
+// version 1 left it for reads to work out. It relies on the ignore cache
+// having been loaded.
+func (s *PebbleList) updateIgnored() error {
+	iter := s.db.NewIter(pathBounds())
+	defer iter.Close()
+
+	walker := newFlagWalker(s.ignoreCache, "/")
+	batch := s.db.NewBatch()
+	for iter.First(); iter.Valid(); iter.Next() {
+		ignored, globally := walker.flags(iter.Key())
+		if wasIgnored, wasGlobally := valueIgnored(iter.Value()); ignored == wasIgnored && globally == wasGlobally {
+			continue
+		}
+
+		batch.Set(iter.Key(), setValueIgnored(iter.Value(), ignored, globally), nil)
+
+		if batch.Count() >= migrateBatchSize {
+			if err := batch.Commit(s.syncOpts()); err != nil {
+				batch.Close()
+				return err
+			}
+			batch.Close()
+			batch = s.db.NewBatch()
+		}
+	}
+
+	if err := batch.Commit(s.syncOpts()); err != nil {
+		batch.Close()
+		return err
+	}
+	return batch.Close()
+}
+
+// refreshIgnored runs updateIgnored if the exclude files, or which ignore
+// files are read, have changed since the flags were last worked out.
+func (s *PebbleList) refreshIgnored() error {
+	state := s.ignoreCache.state()
+
+	value, closer, err := s.db.Get(ignoreStateKey)
+	if err == nil {
+		unchanged := bytes.Equal(value, state)
+		closer.Close()
+		if unchanged {
+			return nil
+		}
+	} else if !errors.Is(err, pebble.ErrNotFound) {
+		return err
+	}
+
+	if err := s.updateIgnored(); err != nil {
+		return err
+	}
+
+	return s.db.Set(ignoreStateKey, state, s.syncOpts())
+}
+
+// syncOpts makes a write durable before it returns. Ephemeral databases have
+// no WAL to sync, and are thrown away anyway.
+func (s *PebbleList) syncOpts() *pebble.WriteOptions {
+	if s.ephemeral {
+		return pebble.NoSync
+	}
+	return pebble.Sync
+}
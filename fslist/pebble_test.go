@@ -1,104 +1,123 @@
 package fslist
 
 import (
-	"fmt"
+	"encoding/json"
 	"os"
-	"sort"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/go-test/deep"
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-var testData = map[string]AddData{
-	"/foo/bar":           AddData{Name: "/foo/bar", IsDir: true},
-	"/foo/bar/baz":       AddData{Name: "/foo/bar/baz", IsDir: true},
-	"/foo/bar/baz/1.txt": AddData{Name: "/foo/bar/baz/1.txt", IsDir: false},
-	"/foo/bar/baz/2.txt": AddData{Name: "/foo/bar/baz/2.txt", IsDir: false},
-	"/foo/bar/qaz":       AddData{Name: "/foo/bar/qaz", IsDir: false},
-}
+func TestOpenPebble(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "open-pebble-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
 
-var __allTestData []AddData
+	gitignore := filepath.Join(tmp, "root", ".gitignore")
+	require.NoError(t, os.MkdirAll(filepath.Dir(gitignore), 0755))
+	require.NoError(t, os.WriteFile(gitignore, []byte("*.log\n"), 0644))
 
-func getTestData(names ...string) []AddData {
-	res := []AddData{}
+	location := filepath.Join(tmp, "index")
+	db, err := OpenPebble(location, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Add(AddData{Name: gitignore}))
+	require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "root", "main.go")}))
+	require.NoError(t, db.Add(AddData{Name: filepath.Join(tmp, "root", "debug.log")}))
+	require.NoError(t, db.Close())
+
+	db, err = OpenPebble(location, Options{})
+	require.NoError(t, err)
+	defer db.Close()
 
-	for _, name := range names {
-		res = append(res, testData[name])
+	assert.Equal(t, Counts{Files: 3}, db.Totals())
+
+	res := []string{}
+	for i := range db.Fetch(ReadOptions{}) {
+		res = append(res, i.Name)
 	}
-	return res
+
+	// The .gitignore is picked up again from the existing entries.
+	assert.Equal(t, []string{gitignore, filepath.Join(tmp, "root", "main.go")}, res)
+}
+
+func TestNewPebbleRemovedOnClose(t *testing.T) {
+	db, err := NewPebble(Options{})
+	require.NoError(t, err)
+
+	location := db.(*PebbleList).location
+	require.NoError(t, db.Close())
+
+	_, err = os.Stat(location)
+	assert.True(t, os.IsNotExist(err), "ephemeral database should be removed, got: %v", err)
 }
 
-func getAllTestData() []AddData {
-	if __allTestData != nil {
-		return __allTestData
+func TestPebbleMigrateJSON(t *testing.T) {
+	location, err := os.MkdirTemp("", "pebble-migrate-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
+
+	// Write a database the way it was before the schema was versioned.
+	db, err := pebble.Open(location, &pebble.Options{})
+	require.NoError(t, err)
+	for _, d := range getAllTestData() {
+		encoded, err := json.Marshal(d)
+		require.NoError(t, err)
+		require.NoError(t, db.Set(d.pebbleKey(), encoded, pebble.Sync))
 	}
+	require.NoError(t, db.Close())
 
-	__allTestData = make([]AddData, 0, len(testData))
-	for _, i := range testData {
-		__allTestData = append(__allTestData, i)
+	list, err := OpenPebble(location, Options{})
+	require.NoError(t, err)
+
+	res := []AddData{}
+	for i := range list.Fetch(ReadOptions{}) {
+		res = append(res, i)
 	}
+	assert.Equal(t, getAllTestData(), res)
+	assert.Equal(t, Counts{Files: 3, Dirs: 2}, list.Totals())
 
-	sort.Sort(ByPath(__allTestData))
+	version, ok, err := list.(*PebbleList).schemaVersion()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, schemaVersion, version)
 
-	return __allTestData
+	// A database from a newer fscache is left alone.
+	require.NoError(t, list.(*PebbleList).setSchemaVersion(schemaVersion+1))
+	require.NoError(t, list.Close())
+
+	_, err = OpenPebble(location, Options{})
+	assert.Error(t, err)
 }
 
-func TestPebble(t *testing.T) {
-	type testCase struct {
-		name     string
-		testData []AddData
-		input    ReadOptions
-		expected []AddData
-	}
+func TestPebbleMigrateIgnoreFlags(t *testing.T) {
+	location, err := os.MkdirTemp("", "pebble-migrate-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(location)
 
-	testCases := []testCase{
-		{
-			name:     "no_options",
-			testData: getAllTestData(),
-			expected: getAllTestData(),
-			input:    ReadOptions{},
-		},
-		{
-			name:     "specific item",
-			testData: getAllTestData(),
-			expected: getTestData("/foo/bar/qaz"),
-			input:    ReadOptions{Prefix: "/foo/bar/qaz"},
-		},
-		{
-			name:     "subtree",
-			testData: getAllTestData(),
-			expected: getTestData("/foo/bar/baz", "/foo/bar/baz/1.txt", "/foo/bar/baz/2.txt"),
-			input:    ReadOptions{Prefix: "/foo/bar/baz"},
-		},
-		{
-			name:     "duplicate adds",
-			testData: append(getAllTestData(), getAllTestData()...),
-			expected: getAllTestData(),
-			input:    ReadOptions{},
-		},
+	tmp, tree := writeIgnoreTree(t)
+
+	// Write a schema version 1 database, which didn't record ignored entries.
+	db, err := pebble.Open(location, &pebble.Options{})
+	require.NoError(t, err)
+	for _, d := range tree {
+		require.NoError(t, db.Set(d.pebbleKey(), encodeValue(d), pebble.Sync))
 	}
+	list := &PebbleList{db: db}
+	require.NoError(t, list.setSchemaVersion(1))
+	require.NoError(t, db.Close())
+
+	opened, err := OpenPebble(location, Options{})
+	require.NoError(t, err)
+	defer opened.Close()
 
-	for _, c := range testCases {
-		t.Run(fmt.Sprintf("test_pebble_fetch_%s", c.name), func(t *testing.T) {
-			db, err := NewPebble()
-			defer db.Close()
-			defer os.RemoveAll(db.(*PebbleList).location)
-			require.NoError(t, err)
-
-			for _, d := range c.testData {
-				db.Add(d)
-			}
-
-			res := []AddData{}
-			for i := range db.Fetch(c.input) {
-				res = append(res, i)
-			}
-
-			if diff := deep.Equal(c.expected, res); diff != nil {
-				t.Errorf("db.Fetch(%#v) =\n%v", c.input, strings.Join(diff, "\n"))
-			}
-		})
+	res := []string{}
+	for i := range opened.Fetch(ReadOptions{}) {
+		res = append(res, strings.TrimPrefix(i.Name, tmp))
 	}
+	assert.Equal(t, []string{"", "/.gitignore", "/build.sh", "/src", "/src/main.go"}, res)
 }
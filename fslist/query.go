@@ -0,0 +1,76 @@
+package fslist
+
+import (
+	"strings"
+	"unicode"
+)
+
+// query is a parsed ReadOptions.Query. Each term is the tokens of one word of
+// the query.
+type query [][]string
+
+func parseQuery(s string) query {
+	q := query{}
+	for _, word := range strings.Fields(s) {
+		if tokens := queryTokens(word); len(tokens) > 0 {
+			q = append(q, tokens)
+		}
+	}
+
+	return q
+}
+
+// queryTokens splits s into lower case tokens at anything other than a letter
+// or digit, as sqlite's unicode61 tokenizer does.
+func queryTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matches reports whether name has every term in it. A term's tokens have to
+// follow each other, with the last a prefix of a token in name.
+func (q query) matches(name string) bool {
+	if len(q) == 0 {
+		return true
+	}
+
+	tokens := queryTokens(name)
+	for _, term := range q {
+		if !hasPhrase(tokens, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasPhrase(tokens, phrase []string) bool {
+	last := len(phrase) - 1
+
+outer:
+	for i := 0; i+last < len(tokens); i++ {
+		for j, token := range phrase[:last] {
+			if tokens[i+j] != token {
+				continue outer
+			}
+		}
+
+		if strings.HasPrefix(tokens[i+last], phrase[last]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fts returns q as an FTS5 MATCH expression, which matches the same entries
+// as matches. Tokens only hold letters and digits, so don't need escaping.
+func (q query) fts() string {
+	phrases := make([]string, 0, len(q))
+	for _, term := range q {
+		phrases = append(phrases, `"`+strings.Join(term, " ")+`"*`)
+	}
+
+	return strings.Join(phrases, " AND ")
+}
@@ -0,0 +1,35 @@
+package fslist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	testCases := []struct {
+		query   string
+		name    string
+		matches bool
+	}{
+		{query: "", name: "/src/fscache/cache.go", matches: true},
+		{query: "cache go", name: "/src/fscache/cache.go", matches: true},
+		{query: "go cache", name: "/src/fscache/cache.go", matches: true},
+		{query: "cach", name: "/src/fscache/cache.go", matches: true},
+		{query: "ache", name: "/src/fscache/cache.go", matches: false},
+		{query: "CACHE.GO", name: "/src/fscache/cache.go", matches: true},
+		{query: "cache.g", name: "/src/fscache/cache.go", matches: true},
+		{query: "go.cache", name: "/src/fscache/cache.go", matches: false},
+		{query: "cache rs", name: "/src/fscache/cache.go", matches: false},
+		{query: "src/fs", name: "/src/fscache/cache.go", matches: true},
+		{query: "//", name: "/src/fscache/cache.go", matches: true},
+	}
+
+	for _, c := range testCases {
+		assert.Equal(t, c.matches, parseQuery(c.query).matches(c.name), "%q matches %q", c.query, c.name)
+	}
+}
+
+func TestQueryFTS(t *testing.T) {
+	assert.Equal(t, `"cache"* AND "cache go"*`, parseQuery("cache  cache.go").fts())
+}
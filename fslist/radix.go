@@ -0,0 +1,223 @@
+package fslist
+
+import (
+	"sort"
+	"strings"
+)
+
+// radixNode is a node of an immutable compressed radix tree. Changes return a
+// new tree, copying only the nodes on the path to the change, so a tree can be
+// read while newer versions of it are being written.
+//
+// The root has an empty prefix. Every other node has a non-empty prefix, and
+// its children start with different bytes and are sorted by them, so walking
+// the tree visits keys in order.
+type radixNode struct {
+	prefix   string
+	value    *AddData
+	children []*radixNode
+}
+
+// clone returns a copy of n which can be changed without changing n.
+func (n *radixNode) clone() *radixNode {
+	c := *n
+	c.children = append([]*radixNode(nil), n.children...)
+	return &c
+}
+
+// child finds the index of the child starting with b, or where it would go.
+func (n *radixNode) child(b byte) (int, bool) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].prefix[0] >= b
+	})
+	return i, i < len(n.children) && n.children[i].prefix[0] == b
+}
+
+func (n *radixNode) get(key string) *AddData {
+	for {
+		if key == "" {
+			return n.value
+		}
+
+		i, ok := n.child(key[0])
+		if !ok || !strings.HasPrefix(key, n.children[i].prefix) {
+			return nil
+		}
+
+		n = n.children[i]
+		key = key[len(n.prefix):]
+	}
+}
+
+// insert sets the value of key, which is relative to n, and reports whether
+// it replaced one.
+func (n *radixNode) insert(key string, value *AddData) (*radixNode, bool) {
+	c := n.clone()
+	if key == "" {
+		c.value = value
+		return c, n.value != nil
+	}
+
+	i, ok := c.child(key[0])
+	if !ok {
+		c.children = append(c.children, nil)
+		copy(c.children[i+1:], c.children[i:])
+		c.children[i] = &radixNode{prefix: key, value: value}
+		return c, false
+	}
+
+	child := c.children[i]
+	l := commonPrefix(child.prefix, key)
+	if l == len(child.prefix) {
+		var replaced bool
+		c.children[i], replaced = child.insert(key[l:], value)
+		return c, replaced
+	}
+
+	// key diverges part way along child's prefix, so it is split there.
+	rest := child.clone()
+	rest.prefix = child.prefix[l:]
+
+	split := &radixNode{prefix: key[:l], children: []*radixNode{rest}}
+	if l == len(key) {
+		split.value = value
+	} else {
+		split, _ = split.insert(key[l:], value)
+	}
+
+	c.children[i] = split
+	return c, false
+}
+
+// remove removes key, which is relative to n, returning the value it had.
+func (n *radixNode) remove(key string) (*radixNode, *AddData) {
+	if key == "" {
+		if n.value == nil {
+			return n, nil
+		}
+
+		c := n.clone()
+		c.value = nil
+		return c, n.value
+	}
+
+	i, ok := n.child(key[0])
+	if !ok || !strings.HasPrefix(key, n.children[i].prefix) {
+		return n, nil
+	}
+
+	child, removed := n.children[i].remove(key[len(n.children[i].prefix):])
+	if removed == nil {
+		return n, nil
+	}
+
+	return n.replaceChild(i, child.compact()), removed
+}
+
+// removePrefix removes every key starting with prefix, which is relative to
+// n, returning how many there were.
+func (n *radixNode) removePrefix(prefix string) (*radixNode, Counts) {
+	i, ok := n.child(prefix[0])
+	if !ok {
+		return n, Counts{}
+	}
+
+	child := n.children[i]
+	switch {
+	case strings.HasPrefix(child.prefix, prefix):
+		// Everything below child starts with prefix.
+		return n.replaceChild(i, nil), child.counts()
+	case strings.HasPrefix(prefix, child.prefix) && len(prefix) > len(child.prefix):
+		newChild, removed := child.removePrefix(prefix[len(child.prefix):])
+		if removed == (Counts{}) {
+			return n, removed
+		}
+		return n.replaceChild(i, newChild.compact()), removed
+	}
+
+	return n, Counts{}
+}
+
+// replaceChild returns a copy of n with child i replaced, or removed if child
+// is nil.
+func (n *radixNode) replaceChild(i int, child *radixNode) *radixNode {
+	c := n.clone()
+	if child == nil {
+		c.children = append(c.children[:i], c.children[i+1:]...)
+	} else {
+		c.children[i] = child
+	}
+	return c
+}
+
+// compact returns nil for a node left with nothing in it, and merges a node
+// without a value into its only child. It isn't used on the root, which has to
+// keep its empty prefix.
+func (n *radixNode) compact() *radixNode {
+	if n.value != nil {
+		return n
+	}
+
+	switch len(n.children) {
+	case 0:
+		return nil
+	case 1:
+		child := n.children[0].clone()
+		child.prefix = n.prefix + child.prefix
+		return child
+	}
+
+	return n
+}
+
+// counts counts the files and directories at and below n.
+func (n *radixNode) counts() Counts {
+	counts := Counts{}
+	n.walk("", "", "", func(_ string, value *AddData) bool {
+		if value.IsDir {
+			counts.Dirs++
+		} else {
+			counts.Files++
+		}
+		return true
+	})
+	return counts
+}
+
+// walk calls fn, in order, with every key from lower up to, but not including,
+// upper. An empty upper has no limit. path is the key of n's parent. fn
+// returns false to stop, in which case so does walk.
+func (n *radixNode) walk(path, lower, upper string, fn func(key string, value *AddData) bool) bool {
+	path += n.prefix
+
+	// Every key below n starts with path, so is at least path.
+	if upper != "" && path >= upper {
+		return true
+	}
+	if path < lower && !strings.HasPrefix(lower, path) {
+		return true
+	}
+
+	if n.value != nil && path >= lower {
+		if !fn(path, n.value) {
+			return false
+		}
+	}
+
+	for _, child := range n.children {
+		if !child.walk(path, lower, upper, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// commonPrefix is the length of the prefix shared by a and b.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,87 @@
+package fslist
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRadixNode changes a tree at random, checking it against a map after
+// every change and that older versions of it are left alone.
+func TestRadixNode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	segments := []string{"a", "ab", "abc", "b", "ba", "/"}
+	randomKey := func() string {
+		key := ""
+		for i := rnd.Intn(6); i >= 0; i-- {
+			key += segments[rnd.Intn(len(segments))]
+		}
+		return key
+	}
+
+	keys := func(root *radixNode, lower, upper string) []string {
+		res := []string{}
+		root.walk("", lower, upper, func(key string, value *AddData) bool {
+			require.Equal(t, key, value.Name)
+			res = append(res, key)
+			return true
+		})
+		return res
+	}
+
+	expect := func(m map[string]bool, lower, upper string) []string {
+		res := []string{}
+		for key := range m {
+			if key >= lower && (upper == "" || key < upper) {
+				res = append(res, key)
+			}
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	root := &radixNode{}
+	want := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		old, oldKeys := root, keys(root, "", "")
+
+		key := randomKey()
+		switch op := rnd.Intn(3); {
+		case op == 0 && want[key]:
+			var removed *AddData
+			root, removed = root.remove(key)
+			require.NotNil(t, removed, "remove(%q)", key)
+			delete(want, key)
+		case op == 1:
+			var removed Counts
+			root, removed = root.removePrefix(key)
+			n := 0
+			for k := range want {
+				if strings.HasPrefix(k, key) {
+					delete(want, k)
+					n++
+				}
+			}
+			require.Equal(t, n, removed.Files, "removePrefix(%q)", key)
+		default:
+			var replaced bool
+			root, replaced = root.insert(key, &AddData{Name: key})
+			require.Equal(t, want[key], replaced, "insert(%q)", key)
+			want[key] = true
+		}
+
+		require.Equal(t, expect(want, "", ""), keys(root, "", ""), fmt.Sprintf("after change %d", i))
+		require.Equal(t, oldKeys, keys(old, "", ""), "older version changed")
+
+		lower, upper := randomKey(), randomKey()
+		assert.Equal(t, expect(want, lower, upper), keys(root, lower, upper), "walk(%q, %q)", lower, upper)
+		for key := range want {
+			assert.NotNil(t, root.get(key), "get(%q)", key)
+		}
+	}
+}
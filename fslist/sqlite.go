@@ -1,168 +1,476 @@
 package fslist
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/keyneston/fscache/internal/shared"
+	"github.com/rs/zerolog"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var _ FSList = &SQList{}
 
-func NewSQL() (FSList, error) {
-	list, err := OpenSQL()
+// NewSQL creates an empty SQList in a temporary directory, which is removed
+// again when it is closed.
+func NewSQL(opts Options) (FSList, error) {
+	location, err := os.MkdirTemp("", "fscache-data-*")
 	if err != nil {
 		return nil, err
 	}
-	s := list.(*SQList)
 
-	return s, s.init()
+	return openSQL(location, true, opts)
 }
 
-func OpenSQL() (FSList, error) {
-	location, err := os.MkdirTemp("", "fscache-data-*")
-	if err != nil {
+// OpenSQL opens an SQList stored in location, creating it if it doesn't exist
+// yet. It is kept between runs, like a PebbleList, unless it was written with
+// another sqlSchemaVersion, in which case it starts out empty.
+func OpenSQL(location string, opts Options) (FSList, error) {
+	if err := os.MkdirAll(location, 0700); err != nil {
 		return nil, err
 	}
-	location = filepath.Join(location, "fscache.sqlite")
 
-	shared.Logger().Debug().Str("database", location).Msg("opening sqlite3 database")
-	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s", location))
+	return openSQL(location, false, opts)
+}
+
+func openSQL(location string, ephemeral bool, opts Options) (FSList, error) {
+	file := filepath.Join(location, "fscache.sqlite")
+
+	logger := shared.Logger().With().Str("module", "sqlite").Logger()
+	logger.Debug().Str("database", file).Bool("ephemeral", ephemeral).Msg("opening sqlite3 database")
+
+	// WAL lets Fetch read while a batch is being written.
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", file))
 	if err != nil {
 		return nil, fmt.Errorf("Error creating SQList: %w", err)
 	}
 
 	s := &SQList{
-		db: db,
+		db:          db,
+		location:    location,
+		ephemeral:   ephemeral,
+		ignoreCache: newIgnoreCache(opts.Ignore, &logger),
+		seq:         firstSeq(),
+		logger:      &logger,
+	}
+
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.refreshIgnored(); err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	return s, nil
 }
 
 type SQList struct {
-	db       *sql.DB
-	location string
+	db          *sql.DB
+	location    string
+	ephemeral   bool
+	ignoreCache *IgnoreCache
+	// fts is set when sqlite has FTS5, so files_fts can be searched.
+	fts bool
+
+	// files and dirs count the rows in files. They are only written when a
+	// batch is committed, and read atomically.
+	files int64
+	dirs  int64
+
+	// seqMu is held to commit a transaction, bump seq and swap in its
+	// ignore rules, and read to start the read transaction of a snapshot or
+	// a batch.
+	seqMu sync.RWMutex
+	seq   uint64
+
+	logger *zerolog.Logger
 }
 
+// sqlSchemaVersion is kept in sqlite's user_version. Tables from any other
+// version are rebuilt, leaving the reconcile on startup to fill them in.
+const sqlSchemaVersion = 5
+
+// init creates the files table. Rows are keyed the same way as a PebbleList,
+// with a trailing '/' for directories, so they sort and are fetched in the
+// same order.
 func (s *SQList) init() error {
-	sqlStmt := `
-DROP INDEX IF EXISTS files_idx_path;
-DROP INDEX IF EXISTS files_idx_prefix_filename;
-DROP TABLE IF EXISTS search_files;
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	if version > sqlSchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", version, sqlSchemaVersion)
+	}
+
+	if version < sqlSchemaVersion {
+		sqlStmt := `
+DROP TRIGGER IF EXISTS files_fts_insert;
+DROP TRIGGER IF EXISTS files_fts_delete;
+DROP TRIGGER IF EXISTS files_fts_update;
 DROP TABLE IF EXISTS files;
-CREATE VIRTUAL TABLE files fts5(filename TEXT PRIMARY KEY UNIQUE, updated_at TIMESTAMP NOT NULL, dir BOOL);
-CREATE INDEX files_idx_path ON files(filename COLLATE NOCASE, dir);
-CREATE INDEX files_idx_prefix_filename ON files(filename COLLATE NOCASE, dir);
-DELETE FROM files;
-	`
+DROP TABLE IF EXISTS meta;
+CREATE TABLE meta (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL
+);
+CREATE TABLE files (
+	key TEXT NOT NULL UNIQUE,
+	dir BOOL NOT NULL,
+	updated_at TIMESTAMP,
+	symlink BOOL NOT NULL DEFAULT 0,
+	target TEXT NOT NULL DEFAULT '',
+	ignored BOOL NOT NULL DEFAULT 0,
+	ignored_globally BOOL NOT NULL DEFAULT 0
+);
+`
+		if _, err := s.db.Exec(sqlStmt); err != nil {
+			return err
+		}
+
+		if _, err := s.db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, sqlSchemaVersion)); err != nil {
+			return err
+		}
+	}
+
+	// FTS5 is only compiled in with the sqlite_fts5 build tag.
+	if err := s.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&s.fts); err != nil {
+		return err
+	}
+
+	return s.initFTS()
+}
+
+// initFTS keeps files_fts, a full text index of the keys in files, in step
+// with it using triggers. Without FTS5 the triggers can't run, so are
+// dropped, and files_fts is rebuilt when they are next created.
+func (s *SQList) initFTS() error {
+	if !s.fts {
+		s.logger.Warn().Msg("sqlite was built without FTS5, so queries scan every key; build with -tags sqlite_fts5 to index them")
+
+		_, err := s.db.Exec(`
+DROP TRIGGER IF EXISTS files_fts_insert;
+DROP TRIGGER IF EXISTS files_fts_delete;
+DROP TRIGGER IF EXISTS files_fts_update;
+`)
+		return err
+	}
+
+	var triggers int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'files_fts_%'`).Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers == 3 {
+		return nil
+	}
+
+	s.logger.Info().Msg("building full text index")
+	sqlStmt := `
+DROP TABLE IF EXISTS files_fts;
+CREATE VIRTUAL TABLE files_fts USING fts5(key, content='files', content_rowid='rowid', tokenize='unicode61');
+CREATE TRIGGER IF NOT EXISTS files_fts_insert AFTER INSERT ON files BEGIN
+	INSERT INTO files_fts(rowid, key) VALUES (new.rowid, new.key);
+END;
+CREATE TRIGGER IF NOT EXISTS files_fts_delete AFTER DELETE ON files BEGIN
+	INSERT INTO files_fts(files_fts, rowid, key) VALUES ('delete', old.rowid, old.key);
+END;
+CREATE TRIGGER IF NOT EXISTS files_fts_update AFTER UPDATE OF key ON files BEGIN
+	INSERT INTO files_fts(files_fts, rowid, key) VALUES ('delete', old.rowid, old.key);
+	INSERT INTO files_fts(rowid, key) VALUES (new.rowid, new.key);
+END;
+INSERT INTO files_fts(files_fts) VALUES ('rebuild');
+`
 	_, err := s.db.Exec(sqlStmt)
+	return err
+}
+
+// load fills the counters and the ignore cache from what is already in the
+// database.
+func (s *SQList) load() error {
+	counts, err := count(s.db, `SELECT dir, COUNT(*) FROM files GROUP BY dir`)
 	if err != nil {
 		return err
 	}
+	atomic.StoreInt64(&s.files, int64(counts.Files))
+	atomic.StoreInt64(&s.dirs, int64(counts.Dirs))
+
+	// Only the .git entries and ignore files are needed, LIKE may let more
+	// through when the names have '_' or '%' in them.
+	match := sq.Or{sq.Like{"key": "%/.git"}, sq.Like{"key": "%/.git/"}}
+	for _, file := range s.ignoreCache.sources.files() {
+		match = append(match, sq.Like{"key": "%/" + file})
+	}
 
+	sqlStmt, args, err := sq.Select("key").From("files").Where(match).ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(sqlStmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	files, repos := []string{}, []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+
+		name, isDir := pebbleKeyName([]byte(key))
+		if dir, ok := repoRoot(name); ok {
+			repos = append(repos, dir)
+		} else if !isDir && s.ignoreCache.isIgnoreFile(name) {
+			files = append(files, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.ignoreCache.load(files, repos)
 	return nil
 }
 
+// refreshIgnored works out again whether every entry is ignored if the exclude
+// files, or which ignore files are read, have changed since it last did. The
+// state it was done with is kept in meta.
+func (s *SQList) refreshIgnored() error {
+	state := s.ignoreCache.state()
+
+	var stored []byte
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = 'ignore_state'`).Scan(&stored)
+	if err == nil && bytes.Equal(stored, state) {
+		return nil
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	b := s.NewBatch().(*sqlBatch)
+	defer b.Close()
+
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.updateIgnored("/"); err != nil {
+		return err
+	}
+	if _, err := b.tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('ignore_state', $1)`, state); err != nil {
+		return err
+	}
+
+	return b.Commit()
+}
+
 func (s *SQList) Pending() bool {
 	// NOOP
 	// SQL writes immediately so there is never pending data
 	return false
 }
 
-func (s *SQList) Add(data AddData) error {
-	sqlStmt := `
-INSERT INTO files (filename, updated_at, dir) VALUES ($1, $2, $3) ON CONFLICT(filename) DO NOTHING;
-`
+func (s *SQList) NewBatch() Batch {
+	s.seqMu.RLock()
+	defer s.seqMu.RUnlock()
 
-	_, err := s.db.Exec(sqlStmt, data.Name, data.UpdatedAt, data.IsDir)
-	return err
+	tx, err := s.db.Begin()
+	return &sqlBatch{list: s, tx: tx, err: err, ignores: batchIgnores{IgnoreCache: s.ignoreCache}}
+}
+
+func (s *SQList) Add(data AddData) error {
+	return s.apply(func(b Batch) error { return b.Add(data) })
 }
 
 func (s *SQList) Delete(data AddData) error {
-	sqlStmt := `delete from files where filename = $1`
+	return s.apply(func(b Batch) error { return b.Delete(data) })
+}
 
-	_, err := s.db.Exec(sqlStmt, data.Name)
-	return err
+func (s *SQList) Move(from, to AddData) error {
+	return s.apply(func(b Batch) error { return b.Move(from, to) })
+}
+
+// apply runs fn in a batch of its own.
+func (s *SQList) apply(fn func(Batch) error) error {
+	b := s.NewBatch()
+	if err := fn(b); err != nil {
+		b.Close()
+		return err
+	}
+
+	return b.Commit()
 }
 
 func (s *SQList) Len() int {
-	// TODO
-	return 0
+	totals := s.Totals()
+	return totals.Files + totals.Dirs
 }
 
-func (s *SQList) Fetch(opts ReadOptions) <-chan AddData {
-	ch := make(chan AddData, 1)
+func (s *SQList) Totals() Counts {
+	return Counts{
+		Files: int(atomic.LoadInt64(&s.files)),
+		Dirs:  int(atomic.LoadInt64(&s.dirs)),
+	}
+}
 
-	go func() {
-		defer close(ch)
+func (s *SQList) Count(opts ReadOptions) (Counts, error) {
+	fetcher, _ := s.newSQLFetcher(s.db, opts)
+	return fetcher.Count()
+}
 
-		logger := shared.Logger().With().Interface("options", opts).Logger()
-		logger.Debug().Msg("fetch called")
+func (s *SQList) Seq() uint64 {
+	return atomic.LoadUint64(&s.seq)
+}
 
-		// sqlite interprets a negative limit as all rows
-		stmt := sq.Select("filename").From("files")
+// Snapshot reads in a transaction. sqlite only fixes what a transaction sees
+// on its first read, so one is made straight away.
+func (s *SQList) Snapshot() (Reader, error) {
+	s.seqMu.RLock()
+	defer s.seqMu.RUnlock()
 
-		if opts.DirsOnly {
-			stmt = stmt.Where(sq.Eq{"dir": true})
-		}
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
 
-		if opts.Prefix != "" {
-			stmt = stmt.Where(sq.Like{"filename": fmt.Sprintf("%s%%", opts.Prefix)})
-		}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM files LIMIT 1)`).Scan(&n); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-		if opts.Limit > 0 {
-			stmt = stmt.OrderBy("updated_at DESC").Limit(uint64(opts.Limit))
-		}
+	return &sqlReader{list: s, tx: tx, seq: atomic.LoadUint64(&s.seq)}, nil
+}
 
-		if opts.Limit == 0 {
-			opts.Limit = -1
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// count runs a query returning a dir, count pair for files and directories.
+func count(db querier, query string, args ...interface{}) (Counts, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer rows.Close()
+
+	counts := Counts{}
+	for rows.Next() {
+		var dir bool
+		var n int
+		if err := rows.Scan(&dir, &n); err != nil {
+			return Counts{}, err
 		}
 
-		sqlStmt, args, err := stmt.ToSql()
-		if err != nil {
-			logger.Error().Err(err).Msg("")
-			return
+		if dir {
+			counts.Dirs += n
+		} else {
+			counts.Files += n
 		}
+	}
+
+	return counts, rows.Err()
+}
+
+func (s *SQList) newSQLFetcher(db querier, opts ReadOptions) (*sqlFetcher, <-chan AddData) {
+	ch := make(chan AddData, 1)
 
-		logger.Debug().Str("sql", sqlStmt).Msg("executing sql")
+	l := s.logger.With().Str("module", "sqlFetcher").Logger()
+	return &sqlFetcher{
+		db:     db,
+		logger: &l,
+		ch:     ch,
+		opts:   opts,
+		query:  parseQuery(opts.Query),
+		fts:    s.fts,
+	}, ch
+}
+
+// Fetch reads in a read only transaction, see Snapshot.
+func (s *SQList) Fetch(opts ReadOptions) <-chan AddData {
+	r, err := s.Snapshot()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("error fetching")
+		ch := make(chan AddData)
+		close(ch)
+		return ch
+	}
+
+	ch := make(chan AddData, 1)
+	go func() {
+		defer close(ch)
+		defer r.Close()
 
-		rows, err := s.db.Query(sqlStmt, args...)
-		if err != nil {
-			logger.Error().Err(err).Msg("")
-			return
+		for data := range r.Fetch(opts) {
+			ch <- data
 		}
-		defer rows.Close()
+	}()
 
-		count := 0
-		for rows.Next() {
-			var filename string
+	return ch
+}
 
-			if err := rows.Scan(&filename); err != nil {
-				logger.Error().Err(err).Msg("")
-				return
-			}
+// sqlReader reads in a read only transaction.
+type sqlReader struct {
+	list *SQList
+	tx   *sql.Tx
+	seq  uint64
+}
 
-			ch <- AddData{
-				Name: filename,
-			}
-			count++
+func (r *sqlReader) Count(opts ReadOptions) (Counts, error) {
+	fetcher, _ := r.list.newSQLFetcher(r.tx, opts)
+	return fetcher.Count()
+}
+
+func (r *sqlReader) Fetch(opts ReadOptions) <-chan AddData {
+	fetcher, ch := r.list.newSQLFetcher(r.tx, opts)
+	go func() {
+		if _, err := fetcher.Fetch(); err != nil {
+			fetcher.logger.Error().Err(err).Msg("error fetching")
 		}
-		shared.Logger().Debug().Int("rows", count).Msg("finished copying")
 	}()
 
 	return ch
 }
 
+func (r *sqlReader) Seq() uint64 {
+	return r.seq
+}
+
+func (r *sqlReader) Close() error {
+	return r.tx.Rollback()
+}
+
 func (s *SQList) Flush() error {
 	// NOOP because SQL doesn't need to flush.
 	return nil
 }
 
 func (s *SQList) Close() error {
-	return s.db.Close()
+	err := s.db.Close()
+
+	if s.ephemeral {
+		if rmErr := os.RemoveAll(s.location); err == nil {
+			err = rmErr
+		}
+	}
+
+	return err
 }
@@ -0,0 +1,322 @@
+package fslist
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var _ Batch = &sqlBatch{}
+
+// sqlBatch is a transaction. As with a PebbleList, ignore files are loaded as
+// they are added, but the counters and the list's ignore rules are only
+// updated once it is committed.
+type sqlBatch struct {
+	list    *SQList
+	tx      *sql.Tx
+	ignores batchIgnores
+	// err is from starting the transaction, and returned by every call.
+	err error
+
+	files int64
+	dirs  int64
+	// changed is set once anything has been written.
+	changed bool
+}
+
+func (b *sqlBatch) Add(data AddData) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.changed = true
+
+	key := string(data.pebbleKey())
+	existed, err := b.exists(key)
+	if err != nil {
+		return err
+	}
+
+	b.ignores.setIgnored(&data)
+	if err := b.insert(key, data.UpdatedAt, data.Symlink, data.Target, data.Ignored, data.IgnoredGlobally); err != nil {
+		return err
+	}
+	if !existed {
+		b.adjust(key, 1)
+	}
+
+	if b.ignores.isIgnoreFile(data.Name) {
+		b.ignores.change().tryAdd(data.Name)
+		return b.updateIgnored(filepath.Dir(data.Name))
+	}
+
+	// The rules from outside a repository stop applying below its root.
+	if dir, ok := repoRoot(data.Name); ok && b.ignores.change().addRepo(dir) {
+		return b.updateIgnored(dir)
+	}
+
+	return nil
+}
+
+func (b *sqlBatch) Delete(data AddData) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.changed = true
+
+	key := string(data.pebbleKey())
+	if !data.IsDir {
+		res, err := b.tx.Exec(`DELETE FROM files WHERE key = $1`, key)
+		if err != nil {
+			return err
+		}
+		if removed, err := res.RowsAffected(); err == nil {
+			b.files -= removed
+		}
+
+		if b.ignores.isIgnoreFile(data.Name) {
+			b.ignores.change().Remove(data.Name)
+			return b.updateIgnored(filepath.Dir(data.Name))
+		}
+		return b.removeRepo(data.Name)
+	}
+
+	// As with a PebbleList the trailing '/' of directory keys keeps siblings
+	// sharing the directory's name as a prefix out of the range.
+	upper := string(calcUpperBound(key))
+
+	// The rows are counted first, so the counters can be brought down by
+	// however many are removed.
+	removed, err := count(b.tx, `SELECT dir, COUNT(*) FROM files WHERE key >= $1 AND key < $2 GROUP BY dir`, key, upper)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.tx.Exec(`DELETE FROM files WHERE key >= $1 AND key < $2`, key, upper); err != nil {
+		return err
+	}
+
+	b.files -= int64(removed.Files)
+	b.dirs -= int64(removed.Dirs)
+	b.ignores.change().removeDir(data.Name)
+	return b.removeRepo(data.Name)
+}
+
+// removeRepo drops the repository when name is its .git, updating what is
+// below its root.
+func (b *sqlBatch) removeRepo(name string) error {
+	if dir, ok := repoRoot(name); ok && b.ignores.change().removeRepo(dir) {
+		return b.updateIgnored(dir)
+	}
+	return nil
+}
+
+func (b *sqlBatch) Move(from, to AddData) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	fromKey := string(from.pebbleKey())
+	query := `SELECT key, updated_at, symlink, target FROM files WHERE key = $1`
+	args := []interface{}{fromKey}
+	if from.IsDir {
+		query = `SELECT key, updated_at, symlink, target FROM files WHERE key >= $1 AND key < $2`
+		args = append(args, string(calcUpperBound(fromKey)))
+	}
+
+	type move struct {
+		from, to  string
+		updatedAt *time.Time
+		symlink   bool
+		target    string
+	}
+
+	// The rows are read before any are moved, as with a PebbleList.
+	rows, err := b.tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	moves := []move{}
+	for rows.Next() {
+		m := move{}
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&m.from, &updatedAt, &m.symlink, &m.target); err != nil {
+			rows.Close()
+			return err
+		}
+		if updatedAt.Valid {
+			m.updatedAt = &updatedAt.Time
+		}
+
+		// Only the key changes, the rest of the row is carried over.
+		name, isDir := pebbleKeyName([]byte(m.from))
+		data := AddData{Name: to.Name + strings.TrimPrefix(name, from.Name), IsDir: isDir}
+		m.to = string(data.pebbleKey())
+
+		moves = append(moves, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(moves) == 0 {
+		return ErrNotIndexed
+	}
+	b.changed = true
+
+	// The rules are brought up to date first, as ignore files apply to
+	// the rows moved along with them.
+	moved := []AddData{}
+	for _, m := range moves {
+		name, isDir := pebbleKeyName([]byte(m.to))
+		moved = append(moved, AddData{Name: name, IsDir: isDir})
+	}
+	changed := b.ignores.change().move(from, to, moved)
+
+	walker := newFlagWalker(b.ignores.IgnoreCache, to.Name)
+	for _, m := range moves {
+		// Moved rows only change the counters when they land on top of
+		// something that is already there.
+		inSource := m.to == fromKey || (from.IsDir && strings.HasPrefix(m.to, fromKey))
+		if !inSource {
+			existed, err := b.exists(m.to)
+			if err != nil {
+				return err
+			}
+			if existed {
+				b.adjust(m.to, -1)
+			}
+		}
+
+		if _, err := b.tx.Exec(`DELETE FROM files WHERE key = $1`, m.from); err != nil {
+			return err
+		}
+		ignored, globally := walker.flags([]byte(m.to))
+		if err := b.insert(m.to, m.updatedAt, m.symlink, m.target, ignored, globally); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range changed {
+		if err := b.updateIgnored(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateIgnored works out again whether dir, and everything below it, is
+// ignored, after the rules which apply to it have changed.
+func (b *sqlBatch) updateIgnored(dir string) error {
+	lower := string(AddData{Name: dir, IsDir: true}.pebbleKey())
+	walker := newFlagWalker(b.ignores.IgnoreCache, dir)
+
+	rows, err := b.tx.Query(`SELECT key, ignored, ignored_globally FROM files WHERE key >= $1 AND key < $2 ORDER BY key`, lower, string(calcUpperBound(lower)))
+	if err != nil {
+		return err
+	}
+
+	type flags struct {
+		ignored, globally bool
+	}
+
+	// As with Move, the rows are read before any are updated.
+	updates := map[string]flags{}
+	for rows.Next() {
+		var key string
+		var was flags
+		if err := rows.Scan(&key, &was.ignored, &was.globally); err != nil {
+			rows.Close()
+			return err
+		}
+
+		now := flags{}
+		now.ignored, now.globally = walker.flags([]byte(key))
+		if now != was {
+			updates[key] = now
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for key, f := range updates {
+		if _, err := b.tx.Exec(`UPDATE files SET ignored = $1, ignored_globally = $2 WHERE key = $3`, f.ignored, f.globally, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *sqlBatch) Commit() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.list.seqMu.Lock()
+	err := b.tx.Commit()
+	if err == nil && b.changed {
+		atomic.AddUint64(&b.list.seq, 1)
+	}
+	if err == nil && b.ignores.changed {
+		b.list.ignoreCache = b.ignores.IgnoreCache
+	}
+	b.list.seqMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&b.list.files, b.files)
+	atomic.AddInt64(&b.list.dirs, b.dirs)
+
+	return nil
+}
+
+func (b *sqlBatch) Close() error {
+	if b.err != nil {
+		return nil
+	}
+
+	err := b.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
+
+// insert writes the row for key, replacing any already there.
+func (b *sqlBatch) insert(key string, updatedAt *time.Time, symlink bool, target string, ignored, globally bool) error {
+	sqlStmt := `
+INSERT INTO files (key, dir, updated_at, symlink, target, ignored, ignored_globally) VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT(key) DO UPDATE SET updated_at = excluded.updated_at, symlink = excluded.symlink, target = excluded.target, ignored = excluded.ignored, ignored_globally = excluded.ignored_globally;
+`
+
+	_, err := b.tx.Exec(sqlStmt, key, isDirKey([]byte(key)), updatedAt, symlink, target, ignored, globally)
+	return err
+}
+
+// adjust changes the counter for the row stored under key by delta.
+func (b *sqlBatch) adjust(key string, delta int64) {
+	if isDirKey([]byte(key)) {
+		b.dirs += delta
+	} else {
+		b.files += delta
+	}
+}
+
+func (b *sqlBatch) exists(key string) (bool, error) {
+	var found int
+	err := b.tx.QueryRow(`SELECT 1 FROM files WHERE key = $1`, key).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
@@ -0,0 +1,218 @@
+package fslist
+
+import (
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rs/zerolog"
+)
+
+// sqlFetcher reads an SQList in the same order, and with the same filters, as
+// a pebbleFetcher reads a PebbleList.
+type sqlFetcher struct {
+	db     querier
+	count  int
+	ch     chan<- AddData
+	opts   ReadOptions
+	query  query
+	fts    bool
+	logger *zerolog.Logger
+}
+
+func (sf *sqlFetcher) Fetch() (int, error) {
+	defer close(sf.ch)
+
+	if sf.fts && len(sf.query) > 0 {
+		return sf.search()
+	}
+
+	if sf.opts.Prefix != "" {
+		return sf.fetchRangeWithPrefix()
+	}
+
+	return sf.fetchRange("", "")
+}
+
+// fetchRangeWithPrefix returns the items from the current working directory
+// onwards first, then the rest of the prefix, see
+// pebbleFetcher.fetchRangeWithPrefix.
+func (sf *sqlFetcher) fetchRangeWithPrefix() (int, error) {
+	lowerBound := sf.opts.Prefix
+	middleBound := lowerBound
+	upperBound := string(calcUpperBound(sf.opts.Prefix))
+
+	if sf.opts.CurrentDir != "" && sf.opts.CurrentDir != sf.opts.Prefix {
+		middleBound = sf.opts.CurrentDir
+	}
+
+	var err error
+	sf.count, err = sf.fetchRange(middleBound, upperBound)
+	if err != nil {
+		return sf.count, err
+	}
+
+	return sf.fetchRange(lowerBound, middleBound)
+}
+
+func (sf *sqlFetcher) fetchRange(lower, upper string) (int, error) {
+	sf.logger.Debug().Dict("bounds",
+		zerolog.Dict().
+			Str("lower", lower).
+			Str("upper", upper),
+	).Msg("Doing a fetchRange")
+
+	err := sf.scan(lower, upper, func(data AddData) (bool, error) {
+		if sf.opts.Limit > 0 && sf.count >= sf.opts.Limit {
+			return false, nil
+		}
+
+		sf.ch <- data
+		sf.count++
+		return true, nil
+	})
+
+	return sf.count, err
+}
+
+// search sends the entries matching the query, the most relevant first, using
+// files_fts.
+func (sf *sqlFetcher) search() (int, error) {
+	stmt := sq.Select("files.key", "files.updated_at", "files.symlink", "files.target", "files.ignored", "files.ignored_globally").
+		From("files_fts").
+		Join("files ON files.rowid = files_fts.rowid").
+		Where("files_fts MATCH ?", sf.query.fts())
+
+	var lower, upper string
+	if sf.opts.Prefix != "" {
+		lower = sf.opts.Prefix
+		upper = string(calcUpperBound(sf.opts.Prefix))
+	}
+
+	err := sf.rows(sf.filter(stmt, "files.", lower, upper).OrderBy("bm25(files_fts)"), func(data AddData) (bool, error) {
+		if sf.opts.Limit > 0 && sf.count >= sf.opts.Limit {
+			return false, nil
+		}
+
+		sf.ch <- data
+		sf.count++
+		return true, nil
+	})
+
+	return sf.count, err
+}
+
+// Count counts the entries Fetch would send, ignoring Limit. Without a query
+// sqlite can do the counting.
+func (sf *sqlFetcher) Count() (Counts, error) {
+	var lower, upper string
+	if sf.opts.Prefix != "" {
+		lower = sf.opts.Prefix
+		upper = string(calcUpperBound(sf.opts.Prefix))
+	}
+
+	if len(sf.query) == 0 {
+		stmt := sf.filter(sq.Select("dir", "COUNT(*)").From("files"), "", lower, upper).GroupBy("dir")
+		sqlStmt, args, err := stmt.ToSql()
+		if err != nil {
+			return Counts{}, err
+		}
+
+		return count(sf.db, sqlStmt, args...)
+	}
+
+	counts := Counts{}
+	err := sf.scan(lower, upper, func(data AddData) (bool, error) {
+		if data.IsDir {
+			counts.Dirs++
+		} else {
+			counts.Files++
+		}
+		return true, nil
+	})
+
+	return counts, err
+}
+
+// scan calls fn with every row from lower to upper which passes the
+// ReadOptions filters, in order. fn returns false to stop early.
+func (sf *sqlFetcher) scan(lower, upper string, fn func(AddData) (bool, error)) error {
+	stmt := sq.Select("key", "updated_at", "symlink", "target", "ignored", "ignored_globally").From("files")
+
+	return sf.rows(sf.filter(stmt, "", lower, upper).OrderBy("key"), func(data AddData) (bool, error) {
+		if !sf.query.matches(data.Name) {
+			sf.logger.Trace().Str("file", data.Name).Msg("skipping non-match")
+			return true, nil
+		}
+		return fn(data)
+	})
+}
+
+// filter limits stmt to the rows from lower to upper which pass the
+// ReadOptions filters, other than the query. table prefixes the columns.
+func (sf *sqlFetcher) filter(stmt sq.SelectBuilder, table, lower, upper string) sq.SelectBuilder {
+	if lower != "" {
+		stmt = stmt.Where(sq.GtOrEq{table + "key": lower})
+	}
+	if upper != "" {
+		stmt = stmt.Where(sq.Lt{table + "key": upper})
+	}
+	switch {
+	case sf.opts.NoIgnore:
+	case sf.opts.NoGlobalIgnore:
+		stmt = stmt.Where(sq.Or{sq.Eq{table + "ignored": false}, sq.Eq{table + "ignored_globally": true}})
+	default:
+		stmt = stmt.Where(sq.Eq{table + "ignored": false})
+	}
+	if sf.opts.NoHidden {
+		// Only the part of the key below the root or prefix counts. substr
+		// counts bytes in a blob, the same as len, rather than characters.
+		rest := fmt.Sprintf("CAST(substr(CAST(%skey AS BLOB), %d) AS TEXT)", table, len(sf.opts.hiddenBase())+1)
+		stmt = stmt.Where(sq.NotLike{rest: ".%"}).Where(sq.NotLike{rest: "%/.%"})
+	}
+	if sf.opts.DirsOnly {
+		stmt = stmt.Where(sq.Eq{table + "dir": true})
+	} else if sf.opts.FilesOnly {
+		stmt = stmt.Where(sq.Eq{table + "dir": false})
+	}
+
+	return stmt
+}
+
+// rows runs stmt, which selects the key, updated_at, symlink, target, ignored
+// and ignored_globally columns of files, calling fn with each row until it
+// returns false.
+func (sf *sqlFetcher) rows(stmt sq.SelectBuilder, fn func(AddData) (bool, error)) error {
+	sqlStmt, args, err := stmt.ToSql()
+	if err != nil {
+		return err
+	}
+
+	sf.logger.Debug().Str("sql", sqlStmt).Msg("executing sql")
+	rows, err := sf.db.Query(sqlStmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var updatedAt sql.NullTime
+		data := AddData{}
+		if err := rows.Scan(&key, &updatedAt, &data.Symlink, &data.Target, &data.Ignored, &data.IgnoredGlobally); err != nil {
+			return err
+		}
+
+		data.Name, data.IsDir = pebbleKeyName([]byte(key))
+		if updatedAt.Valid {
+			t := updatedAt.Time.UTC()
+			data.UpdatedAt = &t
+		}
+
+		if more, err := fn(data); err != nil || !more {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,44 @@
+package fslist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLSearch needs FTS5, so is skipped unless built with -tags sqlite_fts5.
+func TestSQLSearch(t *testing.T) {
+	db, err := NewSQL(Options{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	if !db.(*SQList).fts {
+		t.Skip("sqlite built without FTS5")
+	}
+
+	for _, d := range []AddData{
+		{Name: "/src/a/b/c/d/cache"},
+		{Name: "/src/cache"},
+		{Name: "/src/main.go"},
+	} {
+		require.NoError(t, db.Add(d))
+	}
+
+	names := func(opts ReadOptions) []string {
+		res := []string{}
+		for _, d := range fetchAll(db, opts) {
+			res = append(res, d.Name)
+		}
+		return res
+	}
+
+	// The shorter path is the more relevant, so comes first despite sorting
+	// after the other.
+	assert.Equal(t, []string{"/src/cache", "/src/a/b/c/d/cache"}, names(ReadOptions{Query: "cache"}))
+	assert.Equal(t, []string{"/src/cache"}, names(ReadOptions{Query: "cache", Limit: 1}))
+
+	require.NoError(t, db.Move(AddData{Name: "/src/cache"}, AddData{Name: "/src/moved"}))
+	assert.Equal(t, []string{"/src/moved"}, names(ReadOptions{Query: "moved"}))
+	assert.Equal(t, []string{"/src/a/b/c/d/cache"}, names(ReadOptions{Query: "cache"}))
+}
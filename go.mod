@@ -4,22 +4,21 @@ go 1.16
 
 require (
 	github.com/Masterminds/squirrel v1.5.0
-	github.com/cockroachdb/pebble v0.0.0-20210526183633-dd2a545f5d75 // indirect
+	github.com/cockroachdb/pebble v0.0.0-20210526183633-dd2a545f5d75
 	github.com/fsnotify/fsevents v0.1.1
-	github.com/go-test/deep v1.0.7 // indirect
+	github.com/go-test/deep v1.0.7
 	github.com/google/subcommands v1.2.0
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0 // indirect
 	github.com/mattn/go-sqlite3 v1.14.7
-	github.com/monochromegane/go-gitignore v0.0.0-20200626010858-205db1a8cc00 // indirect
 	github.com/nightlyone/lockfile v1.0.0
-	github.com/rs/zerolog v1.19.0 // indirect
-	github.com/sevlyar/go-daemon v0.1.5 // indirect
+	github.com/rs/zerolog v1.19.0
+	github.com/sevlyar/go-daemon v0.1.5
 	github.com/sirupsen/logrus v1.8.1
 	github.com/slongfield/pyfmt v0.0.0-20180124071345-020a7cb18bca
-	github.com/stretchr/testify v1.6.1 // indirect
+	github.com/stretchr/testify v1.6.1
 	golang.org/x/net v0.0.0-20210525063256-abc453219eb5 // indirect
-	golang.org/x/sys v0.0.0-20210525143221-35b2ab0089ea // indirect
+	golang.org/x/sys v0.0.0-20210525143221-35b2ab0089ea
 	google.golang.org/genproto v0.0.0-20210524171403-669157292da3 // indirect
-	google.golang.org/grpc v1.38.0 // indirect
-	google.golang.org/protobuf v1.26.0 // indirect
+	google.golang.org/grpc v1.38.0
+	google.golang.org/protobuf v1.26.0
 )
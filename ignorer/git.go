@@ -0,0 +1,69 @@
+package ignorer
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExcludesFile finds git's global ignore file, as set by core.excludesFile.
+// Without a setting it is $XDG_CONFIG_HOME/git/ignore, as with git. It
+// returns "" when there is nowhere to look.
+func ExcludesFile() string {
+	if path, err := exec.LookPath("git"); err == nil {
+		buf := &bytes.Buffer{}
+		cmd := exec.Command(path, "config", "--path", "--get", "core.excludesFile")
+		cmd.Stdout = buf
+		// Run outside of any repository, so only the global and system
+		// settings are read.
+		cmd.Dir = "/"
+		if err := cmd.Run(); err == nil {
+			if file := strings.TrimSpace(buf.String()); file != "" {
+				return file
+			}
+		}
+	}
+
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		config = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(config, "git", "ignore")
+}
+
+// InfoExclude returns the exclude file of the repository rooted at dir. The
+// .git of a worktree or submodule is a file pointing at its git directory,
+// and worktrees share the exclude file of the main repository.
+func InfoExclude(dir string) string {
+	gitDir := filepath.Join(dir, ".git")
+
+	if info, err := os.Lstat(gitDir); err == nil && !info.IsDir() {
+		if content, err := os.ReadFile(gitDir); err == nil {
+			if target := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:")); target != "" {
+				gitDir = resolve(dir, target)
+			}
+		}
+
+		if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+			gitDir = resolve(gitDir, strings.TrimSpace(string(common)))
+		}
+	}
+
+	return filepath.Join(gitDir, "info", "exclude")
+}
+
+// resolve makes path absolute, taking it to be relative to dir.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -11,16 +11,18 @@ import (
 	"strings"
 
 	"github.com/keyneston/fscache/internal/shared"
-	"github.com/monochromegane/go-gitignore"
 )
 
 type GlobalIgnore struct {
-	matcher gitignore.IgnoreMatcher
+	rules *Rules
 }
 
+// GlobalIgnoreList returns the built in ignores, one pattern per line. What is
+// in a .git is ignored, but not the .git itself, so the index shows which
+// directories are repositories.
 func GlobalIgnoreList() io.Reader {
 	watchIgnores := `
-.git/
+**/.git/*
 .svn/
 .cvs/
 node_modules/
@@ -28,12 +30,12 @@ Application Support/
 .cache/
 .DS_File
 .Trash
-.rustup/toolchain
-.rustup/update-hashes
-.rustup/tmp
-.rustup/downloads
-pkg/darwin_amd64/
-pkg/darwin_arm/
+**/.rustup/toolchain
+**/.rustup/update-hashes
+**/.rustup/tmp
+**/.rustup/downloads
+**/pkg/darwin_amd64/
+**/pkg/darwin_arm/
 `
 	ignoredKeys := []string{"GOMODCACHE", "GOCACHE", "GOTOOLDIR", "GOROOT"}
 
@@ -91,24 +93,27 @@ func getGoVars() (map[string]string, error) {
 	return vars, nil
 }
 
-func NewGlobalIgnore() GlobalIgnore {
-	matcher := gitignore.NewGitIgnoreFromReader("/", GlobalIgnoreList())
+// NewGlobalIgnore builds a GlobalIgnore from GlobalIgnoreList followed by the
+// user's own patterns, see ReadUserIgnores. The patterns are relative to "/",
+// and as the user's come last they can negate one of the built in ones.
+func NewGlobalIgnore(user []string) GlobalIgnore {
+	list := io.MultiReader(GlobalIgnoreList(), strings.NewReader(strings.Join(user, "\n")))
+
+	// Reading from a buffer can't fail.
+	rules, _ := ParseRules(list)
 
 	return GlobalIgnore{
-		matcher: matcher,
+		rules: rules,
 	}
 }
 
+// Match reports whether path, or any directory above it, is ignored.
 func (g GlobalIgnore) Match(path string, dir bool) bool {
-	// TODO: find a better way:
-	segments := strings.Split(path, "/")
-	for i := range segments {
-		if i != 0 {
-			dir = true
-		}
-
-		path = strings.Join(segments[0:i], "/")
-		if g.matcher.Match(path, dir) {
+	segments := strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+	for i := 1; i <= len(segments); i++ {
+		// Everything but path itself is a directory.
+		isDir := dir || i < len(segments)
+		if g.rules.Match(strings.Join(segments[:i], "/"), isDir) == Ignore {
 			return true
 		}
 	}
@@ -13,11 +13,13 @@ func TestGlobalIgnore(t *testing.T) {
 		expected bool
 	}
 
-	ignoreMatcher := NewGlobalIgnore()
+	ignoreMatcher := NewGlobalIgnore(nil)
 
 	testCases := []testCase{
 		{path: "/foo/bar/.git/baz", dir: false, expected: true},
-		{path: "/foo/bar/.git/", dir: true, expected: true},
+		{path: "/foo/bar/.git/objects", dir: true, expected: true},
+		// .git itself is indexed, so repositories can be found.
+		{path: "/foo/bar/.git", dir: true, expected: false},
 		{path: "/foo/bar/Library/Application Support/foo/bar", dir: false, expected: true},
 		{path: "/Users/Alice/.Trash/Library/foo/bar", dir: false, expected: true},
 	}
@@ -0,0 +1,216 @@
+package ignorer
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Result is what a set of Rules makes of a path.
+type Result int
+
+const (
+	// NoMatch is returned when no pattern matches, leaving it to the rules
+	// of a lower precedence.
+	NoMatch Result = iota
+	// Ignore is returned when the last pattern to match excludes the path.
+	Ignore
+	// Include is returned when the last pattern to match is negated with
+	// '!', so the path isn't ignored whatever lower precedence rules say.
+	Include
+)
+
+// Rules are the patterns of an ignore file, with the semantics of gitignore(5).
+type Rules struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	// basename is set for patterns without a '/', other than a trailing
+	// one, which match a name at any depth.
+	basename bool
+}
+
+// ReadRules reads the rules in file.
+func ReadRules(file string) (*Rules, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f)
+}
+
+// ParseRules reads rules, one pattern per line.
+func ParseRules(r io.Reader) (*Rules, error) {
+	rules := &Rules{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := parsePattern(scanner.Text()); ok {
+			rules.patterns = append(rules.patterns, p)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// Match matches name, which is relative to the directory the rules apply to
+// and separated by '/'. The last pattern to match decides.
+func (r *Rules) Match(name string, isDir bool) Result {
+	for i := len(r.patterns) - 1; i >= 0; i-- {
+		p := r.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		subject := name
+		if p.basename {
+			subject = path.Base(name)
+		}
+
+		if p.re.MatchString(subject) {
+			if p.negate {
+				return Include
+			}
+			return Ignore
+		}
+	}
+
+	return NoMatch
+}
+
+// parsePattern parses a line of an ignore file, returning false for blank
+// lines and comments.
+func parsePattern(line string) (pattern, bool) {
+	line = trimTrailingSpaces(line)
+	if line == "" || line[0] == '#' {
+		return pattern{}, false
+	}
+
+	p := pattern{}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	// A '/' at the start or in the middle anchors the pattern to the
+	// directory of the file.
+	p.basename = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return pattern{}, false
+	}
+	p.re = re
+
+	return p, true
+}
+
+// trimTrailingSpaces removes trailing spaces which aren't escaped with '\'.
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		if end > 1 && line[end-2] == '\\' {
+			break
+		}
+		end--
+	}
+
+	return line[:end]
+}
+
+// globToRegexp converts a gitignore glob into a regular expression. '*' and
+// '?' don't match '/', while "**" matches across directories when it makes
+// up a whole path segment.
+func globToRegexp(glob string) string {
+	var re strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") && (i == 0 || glob[i-1] == '/') {
+				rest := glob[i+2:]
+				switch {
+				case rest == "":
+					re.WriteString(".*")
+					i++
+					continue
+				case rest[0] == '/':
+					// Zero or more directories.
+					re.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			re.WriteString("[^/]*")
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			if class, n := globClass(glob[i:]); n > 0 {
+				re.WriteString(class)
+				i += n - 1
+			} else {
+				re.WriteString(`\[`)
+			}
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	return re.String()
+}
+
+// globClass converts the bracket expression at the start of glob, returning
+// it and how many bytes of glob it took up, or 0 if it isn't closed.
+func globClass(glob string) (string, int) {
+	var class strings.Builder
+	class.WriteString("[")
+
+	i := 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		class.WriteString("^")
+		i++
+	}
+
+	for first := true; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == ']' && !first:
+			class.WriteString("]")
+			return class.String(), i + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			class.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[' || c == ']' || c == '^' || c == '\\':
+			class.WriteString(`\` + string(c))
+		default:
+			class.WriteByte(c)
+		}
+		first = false
+	}
+
+	return "", 0
+}
@@ -0,0 +1,68 @@
+package ignorer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	type testCase struct {
+		rules    string
+		name     string
+		dir      bool
+		expected Result
+	}
+
+	testCases := []testCase{
+		// Without a '/' a pattern matches at any depth.
+		{rules: "*.log", name: "a.log", expected: Ignore},
+		{rules: "*.log", name: "a/b/c.log", expected: Ignore},
+		{rules: "*.log", name: "a.logs", expected: NoMatch},
+		// A leading or middle '/' anchors it.
+		{rules: "/out", name: "out", expected: Ignore},
+		{rules: "/out", name: "src/out", expected: NoMatch},
+		{rules: "docs/*.html", name: "docs/a.html", expected: Ignore},
+		{rules: "docs/*.html", name: "docs/sub/a.html", expected: NoMatch},
+		{rules: "docs/*.html", name: "src/docs/a.html", expected: NoMatch},
+		// A trailing '/' only matches directories.
+		{rules: "build/", name: "build", dir: true, expected: Ignore},
+		{rules: "build/", name: "build", expected: NoMatch},
+		{rules: "build/", name: "a/build", dir: true, expected: Ignore},
+		// "**" matches across directories.
+		{rules: "**/cache", name: "cache", expected: Ignore},
+		{rules: "**/cache", name: "a/b/cache", expected: Ignore},
+		{rules: "vendor/**", name: "vendor/a/b", expected: Ignore},
+		{rules: "vendor/**", name: "vendor", dir: true, expected: NoMatch},
+		{rules: "a/**/b", name: "a/b", expected: Ignore},
+		{rules: "a/**/b", name: "a/x/y/b", expected: Ignore},
+		{rules: "a**b", name: "a/b", expected: NoMatch},
+		{rules: "a**b", name: "axxb", expected: Ignore},
+		// '?' and classes don't match '/'.
+		{rules: "a?c", name: "abc", expected: Ignore},
+		{rules: "a?c", name: "a/c", expected: NoMatch},
+		{rules: "[Tt]emp", name: "Temp", expected: Ignore},
+		{rules: "[!T]emp", name: "Temp", expected: NoMatch},
+		{rules: "[!T]emp", name: "temp", expected: Ignore},
+		{rules: "[a-c]x", name: "bx", expected: Ignore},
+		// The last pattern to match decides.
+		{rules: "*.log\n!keep.log", name: "keep.log", expected: Include},
+		{rules: "!keep.log\n*.log", name: "keep.log", expected: Ignore},
+		// Escapes, comments and trailing spaces.
+		{rules: "\\#notes", name: "#notes", expected: Ignore},
+		{rules: "#notes", name: "#notes", expected: NoMatch},
+		{rules: "\\!important", name: "!important", expected: Ignore},
+		{rules: "trailing   ", name: "trailing", expected: Ignore},
+		{rules: "space\\ ", name: "space ", expected: Ignore},
+		{rules: "a.b", name: "axb", expected: NoMatch},
+	}
+
+	for _, c := range testCases {
+		rules, err := ParseRules(strings.NewReader(c.rules))
+		require.NoError(t, err)
+
+		assert.Equal(t, c.expected, rules.Match(c.name, c.dir), "%q.Match(%q, %v)", c.rules, c.name, c.dir)
+	}
+}
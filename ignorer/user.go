@@ -0,0 +1,180 @@
+package ignorer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultUserIgnoreFile is where the user's own global ignores are kept, one
+// pattern per line as in GlobalIgnoreList.
+var DefaultUserIgnoreFile = "${HOME}/.config/fscache/ignore"
+
+// UserIgnoreFile returns the location of DefaultUserIgnoreFile.
+func UserIgnoreFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(DefaultUserIgnoreFile, "${HOME}", home, -1), nil
+}
+
+// ValidatePattern returns an error if pattern can't be written to an ignore
+// file as a single pattern.
+func ValidatePattern(pattern string) error {
+	if strings.ContainsAny(pattern, "\r\n") {
+		return fmt.Errorf("pattern %q spans lines", pattern)
+	}
+	if _, ok := parsePattern(pattern); !ok {
+		return fmt.Errorf("%q isn't a pattern", pattern)
+	}
+
+	return nil
+}
+
+// ReadUserIgnores returns the patterns in file, leaving out blank lines and
+// comments. A file which doesn't exist has none.
+func ReadUserIgnores(file string) ([]string, error) {
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := []string{}
+	for _, line := range lines {
+		if _, ok := parsePattern(line); ok {
+			patterns = append(patterns, line)
+		}
+	}
+
+	return patterns, nil
+}
+
+// AddUserIgnores appends those of patterns which aren't in file yet,
+// creating it if need be. It returns the ones which were added.
+func AddUserIgnores(file string, patterns []string) ([]string, error) {
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, err
+	}
+
+	present := map[string]bool{}
+	for _, line := range lines {
+		present[line] = true
+	}
+
+	added := []string{}
+	for _, p := range patterns {
+		if err := ValidatePattern(p); err != nil {
+			return nil, err
+		}
+		if present[p] {
+			continue
+		}
+		present[p] = true
+		added = append(added, p)
+	}
+
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	return added, writeLines(file, append(lines, added...))
+}
+
+// RemoveUserIgnores removes the lines of file which are one of patterns,
+// leaving comments and everything else as it was. It returns the ones which
+// were removed.
+func RemoveUserIgnores(file string, patterns []string) ([]string, error) {
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, err
+	}
+
+	remove := map[string]bool{}
+	for _, p := range patterns {
+		remove[p] = true
+	}
+
+	kept := []string{}
+	removed := []string{}
+	for _, line := range lines {
+		if !remove[line] {
+			kept = append(kept, line)
+			continue
+		}
+
+		// Any duplicates go too, but are only reported once.
+		if !contains(removed, line) {
+			removed = append(removed, line)
+		}
+	}
+
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	return removed, writeLines(file, kept)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readLines reads file a line at a time, returning nothing if it doesn't
+// exist.
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+// writeLines replaces file with lines. It is written alongside and renamed
+// into place, so a reader never sees it half written.
+func writeLines(file string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,64 @@
+package ignorer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserIgnores(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "user-ignores-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	file := filepath.Join(tmp, "config", "ignore")
+
+	// A missing file has no patterns, and is created on the first add.
+	patterns, err := ReadUserIgnores(file)
+	require.NoError(t, err)
+	assert.Empty(t, patterns)
+
+	added, err := AddUserIgnores(file, []string{"dist/", "*.bak", "dist/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dist/", "*.bak"}, added)
+
+	added, err = AddUserIgnores(file, []string{"*.bak", "/opt/big"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/opt/big"}, added)
+
+	// Comments written by hand are kept, but aren't patterns.
+	contents, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, append([]byte("# by hand\n\n"), contents...), 0644))
+
+	patterns, err = ReadUserIgnores(file)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dist/", "*.bak", "/opt/big"}, patterns)
+
+	removed, err := RemoveUserIgnores(file, []string{"*.bak", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*.bak"}, removed)
+
+	contents, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "# by hand\n\ndist/\n/opt/big\n", string(contents))
+
+	_, err = AddUserIgnores(file, []string{"# comment"})
+	assert.Error(t, err)
+	_, err = AddUserIgnores(file, []string{"two\nlines"})
+	assert.Error(t, err)
+}
+
+func TestNewGlobalIgnore_User(t *testing.T) {
+	ignore := NewGlobalIgnore([]string{"dist/", "!node_modules/"})
+
+	assert.True(t, ignore.Match("/src/app/dist", true))
+	assert.True(t, ignore.Match("/src/app/dist/bundle.js", false))
+	assert.False(t, ignore.Match("/src/app/dist", false))
+	// The user's patterns come last, so can negate a built in one.
+	assert.False(t, ignore.Match("/src/app/node_modules", true))
+	assert.True(t, ignore.Match("/src/.git/config", false))
+}
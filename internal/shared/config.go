@@ -1,9 +1,12 @@
 package shared
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -22,6 +25,10 @@ type Config struct {
 
 var DefaultSocketLocation = "${HOME}/.cache/fscache.socket"
 
+// DefaultIndexLocation is where persistent indexes are kept, in a directory
+// per root, see IndexLocation.
+var DefaultIndexLocation = "${HOME}/.cache/fscache"
+
 func (c *Config) SetFlags(f *flag.FlagSet) {
 	if f != nil {
 		f.BoolVar(&debug, "debug", false, "Enable verbose debug logging")
@@ -70,6 +77,25 @@ func (c *Config) SocketLocation() (string, error) {
 	return strings.Replace(DefaultSocketLocation, "${HOME}", home, -1), nil
 }
 
+// IndexLocation returns where the persistent index of root is kept for mode.
+// Roots are told apart by a hash of their path, so each gets its own index.
+func IndexLocation(root, mode string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(root))
+	base := strings.Replace(DefaultIndexLocation, "${HOME}", home, -1)
+
+	return filepath.Join(base, hex.EncodeToString(sum[:8]), mode), nil
+}
+
 func Exitf(format string, vars ...interface{}) subcommands.ExitStatus {
 	if len(format) == 0 || format[len(format)-1] != '\n' {
 		format = fmt.Sprintf("%s\n", format)
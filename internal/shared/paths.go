@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CleanPrefix adds a trailing '/' to a prefix if it is set and it doesn't have
+// one
+func CleanPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+
+	if prefix[len(prefix)-1] != '/' {
+		prefix = fmt.Sprintf("%s/", prefix)
+	}
+	return prefix
+}
+
+// roots mark the top of a repository.
+var roots = map[string]bool{
+	".git": true,
+	".svn": true,
+	".hg":  true,
+}
+
+// FindRoot returns the closest directory, from the working directory up,
+// which holds a .git, .svn or .hg. If there is none it returns "/".
+func FindRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if dir == "/" {
+			return dir, nil
+		}
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+
+		for _, f := range files {
+			name := f.Name()
+
+			// Only check dot files. These (should?!?) come before non dot
+			// files, so abort as soon as we hit non dot files
+			if name[0] != '.' {
+				break
+			}
+			if _, ok := roots[name]; ok {
+				return dir, nil
+			}
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,98 @@
+package walk
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// DefaultSkipFSTypes are pseudo filesystems which never hold anything worth
+// indexing, but are mounted below / on every system.
+var DefaultSkipFSTypes = []string{
+	"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs", "debugfs",
+	"devfs", "devpts", "efivarfs", "fusectl", "hugetlbfs", "mqueue", "nsfs",
+	"proc", "pstore", "securityfs", "sysfs", "tracefs",
+}
+
+// Boundary decides which filesystems are descended into. A nil Boundary
+// allows everything.
+type Boundary struct {
+	oneFileSystem bool
+	skipTypes     map[string]bool
+	rootDev       uint64
+
+	lock sync.Mutex
+	// excluded caches the decision for every device seen, so statfs is only
+	// called once per filesystem.
+	excluded map[uint64]bool
+}
+
+// NewBoundary creates a Boundary for a walk from root. If oneFileSystem is set
+// nothing outside root's filesystem is allowed, otherwise only filesystems
+// whose type, as returned by FSType, is in skipTypes are excluded. root's own
+// filesystem is always allowed. If nothing would be excluded it returns nil.
+func NewBoundary(root string, oneFileSystem bool, skipTypes []string) (*Boundary, error) {
+	if !oneFileSystem && len(skipTypes) == 0 {
+		return nil, nil
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Boundary{
+		oneFileSystem: oneFileSystem,
+		skipTypes:     map[string]bool{},
+		rootDev:       device(info),
+		excluded:      map[uint64]bool{},
+	}
+	for _, t := range skipTypes {
+		b.skipTypes[t] = true
+	}
+
+	return b, nil
+}
+
+// Excluded reports whether d, found at path, is a directory on a filesystem
+// which shouldn't be descended into.
+func (b *Boundary) Excluded(path string, d fs.DirEntry) bool {
+	if b == nil || d == nil || !d.IsDir() {
+		return false
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+
+	dev := device(info)
+	if dev == b.rootDev {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if excluded, ok := b.excluded[dev]; ok {
+		return excluded
+	}
+
+	excluded := b.oneFileSystem
+	if !excluded && len(b.skipTypes) > 0 {
+		fsType, err := FSType(path)
+		excluded = err == nil && b.skipTypes[fsType]
+	}
+
+	b.excluded[dev] = excluded
+	return excluded
+}
+
+func device(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev)
+	}
+
+	return 0
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package walk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// mountTmpfs mounts a tmpfs below a new root, skipping the test if that isn't
+// allowed.
+func mountTmpfs(t *testing.T) (string, string) {
+	tmp, err := os.MkdirTemp("", "boundary-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+
+	mnt := filepath.Join(tmp, "mnt")
+	require.NoError(t, os.Mkdir(mnt, 0755))
+
+	if err := unix.Mount("tmpfs", mnt, "tmpfs", 0, ""); err != nil {
+		t.Skipf("unable to mount tmpfs: %v", err)
+	}
+	t.Cleanup(func() { unix.Unmount(mnt, 0) })
+
+	return tmp, mnt
+}
+
+func TestBoundary(t *testing.T) {
+	root, mnt := mountTmpfs(t)
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+
+	fsType, err := FSType(mnt)
+	require.NoError(t, err)
+	assert.Equal(t, "tmpfs", fsType)
+
+	excluded := func(b *Boundary, path string) bool {
+		d, err := Entry(path, false)
+		require.NoError(t, err)
+		return b.Excluded(path, d)
+	}
+
+	var none *Boundary
+	assert.False(t, excluded(none, mnt))
+
+	b, err := NewBoundary(root, true, nil)
+	require.NoError(t, err)
+	assert.True(t, excluded(b, mnt))
+	assert.False(t, excluded(b, filepath.Join(root, "dir")))
+	assert.False(t, excluded(b, root))
+
+	b, err = NewBoundary(root, false, []string{"tmpfs"})
+	require.NoError(t, err)
+	assert.True(t, excluded(b, mnt))
+
+	b, err = NewBoundary(root, false, DefaultSkipFSTypes)
+	require.NoError(t, err)
+	assert.False(t, excluded(b, mnt))
+}
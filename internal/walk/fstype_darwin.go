@@ -0,0 +1,20 @@
+//go:build darwin
+// +build darwin
+
+package walk
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FSType returns the type of the filesystem path is on.
+func FSType(path string) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return "", os.NewSyscallError("statfs", err)
+	}
+
+	return unix.ByteSliceToString(stat.Fstypename[:]), nil
+}
@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package walk
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsTypes names the filesystem magic numbers returned by statfs(2). Anything
+// not listed is named by its number, such as 0x1234abcd.
+var fsTypes = map[int64]string{
+	unix.AUTOFS_SUPER_MAGIC:    "autofs",
+	unix.BINFMTFS_MAGIC:        "binfmt_misc",
+	unix.BPF_FS_MAGIC:          "bpf",
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	unix.CGROUP_SUPER_MAGIC:    "cgroup",
+	unix.CGROUP2_SUPER_MAGIC:   "cgroup2",
+	0x62656570:                 "configfs",
+	0xff534d42:                 "cifs",
+	unix.DEBUGFS_MAGIC:         "debugfs",
+	unix.DEVPTS_SUPER_MAGIC:    "devpts",
+	unix.ECRYPTFS_SUPER_MAGIC:  "ecryptfs",
+	unix.EFIVARFS_MAGIC:        "efivarfs",
+	unix.EXT4_SUPER_MAGIC:      "ext4",
+	unix.F2FS_SUPER_MAGIC:      "f2fs",
+	0x65735546:                 "fuse",
+	0x65735543:                 "fusectl",
+	unix.HUGETLBFS_MAGIC:       "hugetlbfs",
+	unix.ISOFS_SUPER_MAGIC:     "iso9660",
+	0x19800202:                 "mqueue",
+	unix.MSDOS_SUPER_MAGIC:     "vfat",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.NSFS_MAGIC:            "nsfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlay",
+	unix.PROC_SUPER_MAGIC:      "proc",
+	unix.PSTOREFS_MAGIC:        "pstore",
+	unix.RAMFS_MAGIC:           "ramfs",
+	unix.SECURITYFS_MAGIC:      "securityfs",
+	0xfe534d42:                 "smb2",
+	unix.SQUASHFS_MAGIC:        "squashfs",
+	unix.SYSFS_MAGIC:           "sysfs",
+	unix.TMPFS_MAGIC:           "tmpfs",
+	unix.TRACEFS_MAGIC:         "tracefs",
+	unix.V9FS_MAGIC:            "9p",
+	unix.XFS_SUPER_MAGIC:       "xfs",
+	0x2fc12fc1:                 "zfs",
+}
+
+// FSType returns the type of the filesystem path is on.
+func FSType(path string) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return "", os.NewSyscallError("statfs", err)
+	}
+
+	if name, ok := fsTypes[int64(stat.Type)]; ok {
+		return name, nil
+	}
+
+	return fmt.Sprintf("%#x", stat.Type), nil
+}
@@ -0,0 +1,164 @@
+// Package walk walks directory trees like filepath.WalkDir, with the option of
+// following symlinks to directories.
+package walk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrSymlinkLoop is passed to the WalkDirFunc, in a second call for the link,
+// when a symlink points back at one of its own ancestors and so isn't
+// followed.
+var ErrSymlinkLoop = errors.New("symlink loop")
+
+// Walk calls fn for root and everything below it, in the same way as
+// filepath.WalkDir. If follow is set, symlinks to directories are walked as
+// well and their contents reported under the link's path.
+//
+// A followed link is passed to fn as a directory whose Type also has
+// fs.ModeSymlink set, and whose Info describes the target. Loops are found by
+// comparing the device and inode of a link's target with the directories
+// above it, in which case the link is reported as a plain symlink.
+func Walk(root string, follow bool, fn fs.WalkDirFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(root, fs.FileInfoToDirEntry(info), follow, ancestors(root, follow), fn)
+	}
+
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// Entry returns the DirEntry for path as Walk would report it.
+func Entry(path string, follow bool) (fs.DirEntry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := fs.FileInfoToDirEntry(info)
+	if !follow || info.Mode()&fs.ModeSymlink == 0 {
+		return d, nil
+	}
+
+	d, _ = resolve(path, d, ancestors(path, follow))
+	return d, nil
+}
+
+// resolve returns the followed entry for the symlink d, or d itself if it
+// doesn't point to a directory. If following it would loop d is returned
+// along with ErrSymlinkLoop.
+func resolve(path string, d fs.DirEntry, above []fs.FileInfo) (fs.DirEntry, error) {
+	target, err := os.Stat(path)
+	if err != nil || !target.IsDir() {
+		// Dangling, or a link to a file.
+		return d, nil
+	}
+
+	for _, dir := range above {
+		if os.SameFile(dir, target) {
+			return d, ErrSymlinkLoop
+		}
+	}
+
+	return linkEntry{DirEntry: d, target: target}, nil
+}
+
+// ancestors stats every directory above path. Symlinks along the way are
+// followed, so these are the directories path really is in.
+func ancestors(path string, follow bool) []fs.FileInfo {
+	if !follow {
+		return nil
+	}
+
+	res := []fs.FileInfo{}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil {
+			res = append(res, info)
+		}
+
+		if dir == filepath.Dir(dir) {
+			return res
+		}
+	}
+}
+
+func walk(path string, d fs.DirEntry, follow bool, above []fs.FileInfo, fn fs.WalkDirFunc) error {
+	if follow && d.Type()&fs.ModeSymlink != 0 && !d.IsDir() {
+		resolved, loopErr := resolve(path, d, above)
+		if loopErr != nil {
+			if err := fn(path, d, nil); err != nil {
+				return err
+			}
+			if err := fn(path, d, loopErr); err != filepath.SkipDir {
+				return err
+			}
+			return nil
+		}
+		d = resolved
+	}
+
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			// Don't descend into this directory, but carry on with its
+			// siblings.
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		err = fn(path, d, err)
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	if follow {
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		above = append(above[:len(above):len(above)], info)
+	}
+
+	for _, entry := range entries {
+		err := walk(filepath.Join(path, entry.Name()), entry, follow, above, fn)
+		if err == filepath.SkipDir {
+			// Skip the rest of this directory.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkEntry is a symlink that has been followed to a directory.
+type linkEntry struct {
+	fs.DirEntry
+	target fs.FileInfo
+}
+
+func (e linkEntry) IsDir() bool {
+	return true
+}
+
+func (e linkEntry) Type() fs.FileMode {
+	return fs.ModeDir | fs.ModeSymlink
+}
+
+func (e linkEntry) Info() (fs.FileInfo, error) {
+	return e.target, nil
+}
@@ -0,0 +1,126 @@
+package walk
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup creates:
+//
+//	root/dir/file.txt
+//	root/dir/loop -> root
+//	root/link -> data
+//	data/nested.txt
+func setup(t *testing.T) (string, string) {
+	tmp, err := os.MkdirTemp("", "walk-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+
+	root := filepath.Join(tmp, "root")
+	data := filepath.Join(tmp, "data")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0755))
+	require.NoError(t, os.Mkdir(data, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "file.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "nested.txt"), nil, 0644))
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "dir", "loop")))
+	require.NoError(t, os.Symlink(data, filepath.Join(root, "link")))
+
+	return root, data
+}
+
+type visited struct {
+	path string
+	dir  bool
+	link bool
+}
+
+func collect(t *testing.T, root string, follow bool) ([]visited, []string) {
+	res := []visited{}
+	loops := []string{}
+
+	err := Walk(root, follow, func(path string, d fs.DirEntry, err error) error {
+		if err == ErrSymlinkLoop {
+			loops = append(loops, path)
+			return nil
+		}
+		require.NoError(t, err)
+
+		rel, err := filepath.Rel(root, path)
+		require.NoError(t, err)
+		res = append(res, visited{path: rel, dir: d.IsDir(), link: d.Type()&fs.ModeSymlink != 0})
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Slice(res, func(i, j int) bool { return res[i].path < res[j].path })
+	return res, loops
+}
+
+func TestWalk(t *testing.T) {
+	root, _ := setup(t)
+
+	res, loops := collect(t, root, false)
+	assert.Equal(t, []visited{
+		{path: ".", dir: true},
+		{path: "dir", dir: true},
+		{path: "dir/file.txt"},
+		{path: "dir/loop", link: true},
+		{path: "link", link: true},
+	}, res)
+	assert.Empty(t, loops)
+}
+
+func TestWalkFollow(t *testing.T) {
+	root, _ := setup(t)
+
+	res, loops := collect(t, root, true)
+	assert.Equal(t, []visited{
+		{path: ".", dir: true},
+		{path: "dir", dir: true},
+		{path: "dir/file.txt"},
+		{path: "dir/loop", link: true},
+		{path: "link", dir: true, link: true},
+		{path: "link/nested.txt"},
+	}, res)
+	assert.Equal(t, []string{filepath.Join(root, "dir", "loop")}, loops)
+}
+
+func TestWalkFollowLinkedRoot(t *testing.T) {
+	root, _ := setup(t)
+	link := filepath.Join(root, "link")
+
+	res, _ := collect(t, link, true)
+	assert.Equal(t, []visited{
+		{path: ".", dir: true, link: true},
+		{path: "nested.txt"},
+	}, res)
+
+	// Starting below the loop still notices that it leads back up.
+	res, loops := collect(t, filepath.Join(root, "dir", "loop"), true)
+	assert.Equal(t, []visited{{path: ".", link: true}}, res)
+	assert.Len(t, loops, 1)
+}
+
+func TestEntry(t *testing.T) {
+	root, _ := setup(t)
+
+	d, err := Entry(filepath.Join(root, "link"), true)
+	require.NoError(t, err)
+	assert.True(t, d.IsDir())
+	assert.NotZero(t, d.Type()&fs.ModeSymlink)
+
+	d, err = Entry(filepath.Join(root, "link"), false)
+	require.NoError(t, err)
+	assert.False(t, d.IsDir())
+
+	d, err = Entry(filepath.Join(root, "dir", "loop"), true)
+	require.NoError(t, err)
+	assert.False(t, d.IsDir())
+}
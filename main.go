@@ -6,9 +6,13 @@ import (
 	"os"
 
 	"github.com/google/subcommands"
+	"github.com/keyneston/fscache/cmds/count"
+	"github.com/keyneston/fscache/cmds/ignore"
 	listignores "github.com/keyneston/fscache/cmds/list-ignores"
 	"github.com/keyneston/fscache/cmds/read"
+	"github.com/keyneston/fscache/cmds/replay"
 	"github.com/keyneston/fscache/cmds/run"
+	"github.com/keyneston/fscache/cmds/stats"
 	"github.com/keyneston/fscache/cmds/stop"
 	"github.com/keyneston/fscache/internal/shared"
 )
@@ -24,6 +28,10 @@ func main() {
 	subcommands.Register(&read.Command{Config: sharedConf}, "")
 	subcommands.Register(&stop.Command{Config: sharedConf}, "")
 	subcommands.Register(&listignores.Command{Config: sharedConf}, "")
+	subcommands.Register(&stats.Command{Config: sharedConf}, "")
+	subcommands.Register(&count.Command{Config: sharedConf}, "")
+	subcommands.Register(&replay.Command{Config: sharedConf}, "")
+	subcommands.Register(&ignore.Command{Config: sharedConf}, "")
 
 	flag.Parse()
 	ctx := context.Background()
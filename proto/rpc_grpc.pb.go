@@ -21,6 +21,11 @@ const _ = grpc.SupportPackageIsVersion7
 type FSCacheClient interface {
 	GetFiles(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (FSCache_GetFilesClient, error)
 	Shutdown(ctx context.Context, in *ShutdownRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Stats, error)
+	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*Counts, error)
+	AddIgnores(ctx context.Context, in *IgnoreRequest, opts ...grpc.CallOption) (*Ignores, error)
+	RemoveIgnores(ctx context.Context, in *IgnoreRequest, opts ...grpc.CallOption) (*Ignores, error)
+	ListIgnores(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Ignores, error)
 }
 
 type fSCacheClient struct {
@@ -72,12 +77,62 @@ func (c *fSCacheClient) Shutdown(ctx context.Context, in *ShutdownRequest, opts
 	return out, nil
 }
 
+func (c *fSCacheClient) GetStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, "/FSCache/GetStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSCacheClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*Counts, error) {
+	out := new(Counts)
+	err := c.cc.Invoke(ctx, "/FSCache/Count", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSCacheClient) AddIgnores(ctx context.Context, in *IgnoreRequest, opts ...grpc.CallOption) (*Ignores, error) {
+	out := new(Ignores)
+	err := c.cc.Invoke(ctx, "/FSCache/AddIgnores", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSCacheClient) RemoveIgnores(ctx context.Context, in *IgnoreRequest, opts ...grpc.CallOption) (*Ignores, error) {
+	out := new(Ignores)
+	err := c.cc.Invoke(ctx, "/FSCache/RemoveIgnores", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSCacheClient) ListIgnores(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Ignores, error) {
+	out := new(Ignores)
+	err := c.cc.Invoke(ctx, "/FSCache/ListIgnores", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FSCacheServer is the server API for FSCache service.
 // All implementations must embed UnimplementedFSCacheServer
 // for forward compatibility
 type FSCacheServer interface {
 	GetFiles(*ListRequest, FSCache_GetFilesServer) error
 	Shutdown(context.Context, *ShutdownRequest) (*emptypb.Empty, error)
+	GetStats(context.Context, *emptypb.Empty) (*Stats, error)
+	Count(context.Context, *CountRequest) (*Counts, error)
+	AddIgnores(context.Context, *IgnoreRequest) (*Ignores, error)
+	RemoveIgnores(context.Context, *IgnoreRequest) (*Ignores, error)
+	ListIgnores(context.Context, *emptypb.Empty) (*Ignores, error)
 	mustEmbedUnimplementedFSCacheServer()
 }
 
@@ -91,6 +146,21 @@ func (UnimplementedFSCacheServer) GetFiles(*ListRequest, FSCache_GetFilesServer)
 func (UnimplementedFSCacheServer) Shutdown(context.Context, *ShutdownRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Shutdown not implemented")
 }
+func (UnimplementedFSCacheServer) GetStats(context.Context, *emptypb.Empty) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedFSCacheServer) Count(context.Context, *CountRequest) (*Counts, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+func (UnimplementedFSCacheServer) AddIgnores(context.Context, *IgnoreRequest) (*Ignores, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddIgnores not implemented")
+}
+func (UnimplementedFSCacheServer) RemoveIgnores(context.Context, *IgnoreRequest) (*Ignores, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveIgnores not implemented")
+}
+func (UnimplementedFSCacheServer) ListIgnores(context.Context, *emptypb.Empty) (*Ignores, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListIgnores not implemented")
+}
 func (UnimplementedFSCacheServer) mustEmbedUnimplementedFSCacheServer() {}
 
 // UnsafeFSCacheServer may be embedded to opt out of forward compatibility for this service.
@@ -143,6 +213,96 @@ func _FSCache_Shutdown_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FSCache_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSCacheServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/FSCache/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSCacheServer).GetStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSCache_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSCacheServer).Count(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/FSCache/Count",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSCacheServer).Count(ctx, req.(*CountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSCache_AddIgnores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IgnoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSCacheServer).AddIgnores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/FSCache/AddIgnores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSCacheServer).AddIgnores(ctx, req.(*IgnoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSCache_RemoveIgnores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IgnoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSCacheServer).RemoveIgnores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/FSCache/RemoveIgnores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSCacheServer).RemoveIgnores(ctx, req.(*IgnoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSCache_ListIgnores_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSCacheServer).ListIgnores(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/FSCache/ListIgnores",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSCacheServer).ListIgnores(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FSCache_ServiceDesc is the grpc.ServiceDesc for FSCache service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -154,6 +314,26 @@ var FSCache_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Shutdown",
 			Handler:    _FSCache_Shutdown_Handler,
 		},
+		{
+			MethodName: "GetStats",
+			Handler:    _FSCache_GetStats_Handler,
+		},
+		{
+			MethodName: "Count",
+			Handler:    _FSCache_Count_Handler,
+		},
+		{
+			MethodName: "AddIgnores",
+			Handler:    _FSCache_AddIgnores_Handler,
+		},
+		{
+			MethodName: "RemoveIgnores",
+			Handler:    _FSCache_RemoveIgnores_Handler,
+		},
+		{
+			MethodName: "ListIgnores",
+			Handler:    _FSCache_ListIgnores_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,42 @@
+package integration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/keyneston/fscache/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestCount(t *testing.T) {
+	i := New(t, "integration-count")
+
+	i.createFile(".gitignore").with("*.log").done()
+	i.createFile("debug.log").done()
+	i.createFile("src", "main.go").done()
+	i.createFile("src", "pkg", "lib.go").done()
+	i.createFile("README.md").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	count := func(req *proto.CountRequest) (uint64, uint64) {
+		counts, err := i.client.Count(context.Background(), req)
+		i.require.NoError(err, "Error counting")
+		return counts.Files, counts.Dirs
+	}
+
+	files, dirs := count(&proto.CountRequest{})
+	i.assert.Equal(uint64(4), files, "ignored files aren't counted")
+	i.assert.Equal(uint64(3), dirs)
+
+	files, dirs = count(&proto.CountRequest{Prefix: filepath.Join(i.testDir, "src") + "/", FilesOnly: true})
+	i.assert.Equal(uint64(2), files)
+	i.assert.Equal(uint64(0), dirs)
+
+	stats, err := i.client.GetStats(context.Background(), &emptypb.Empty{})
+	i.require.NoError(err, "Error getting stats")
+	i.assert.Equal(uint64(5), stats.Files, "stats count ignored files too")
+	i.assert.Equal(uint64(3), stats.Dirs)
+}
@@ -2,7 +2,7 @@ package integration
 
 import (
 	"context"
-	"io"
+	"os"
 	"path/filepath"
 	"sort"
 	"testing"
@@ -10,39 +10,33 @@ import (
 
 	"github.com/keyneston/fscache/fslist"
 	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 func TestIgnoreEndToEnd(t *testing.T) {
 	i := New(t, "integration-ignore")
 
-	go i.cache.Run()
+	i.start()
 	defer i.CleanUp()
 
-	i.createFile(".gitignore").with("*.ignored").done()
-	i.createFile("foo.ignored").done()
-	i.createFile("bar.ignored").done()
-	i.createFile("dir.ignored/this-file").done()
-	i.createFile("bar.not").done()
-
-	time.Sleep(2 * time.Second)
-
-	stream, err := i.client.GetFiles(context.Background(), &proto.ListRequest{})
-	i.require.NoError(err, "Error getting files")
-
-	res := []fslist.AddData{}
-	for {
-		files, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			t.Fatalf("Error receiving files: %v", err)
-		}
-
-		for _, f := range files.Files {
-			res = append(res, fslist.AddDataFromProtoFile(f))
-		}
-	}
+	gitignore := i.createFile(".gitignore").with("*.ignored").done()
+	fooIgnored := i.createFile("foo.ignored").done()
+	barIgnored := i.createFile("bar.ignored").done()
+	dirIgnored := i.createFile("dir.ignored/this-file").done()
+	barNot := i.createFile("bar.not").done()
+
+	i.apply(
+		watcher.Event{Path: gitignore, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: fooIgnored, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: barIgnored, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: filepath.Dir(dirIgnored), Type: watcher.EventTypeAdd, Dir: true},
+		watcher.Event{Path: dirIgnored, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: barNot, Type: watcher.EventTypeAdd},
+	)
+
+	res := i.getFiles(&proto.ListRequest{})
 
 	expected := []fslist.AddData{
 		{Name: filepath.Join(i.testDir, ".gitignore"), IsDir: false},
@@ -50,9 +44,116 @@ func TestIgnoreEndToEnd(t *testing.T) {
 		{Name: i.testDir, IsDir: true},
 	}
 
-	sort.Sort(fslist.ByPath(res))
-	sort.Sort(fslist.ByPath(expected))
-
 	i.assert.Len(res, 3)
 	i.assert.ElementsMatch(expected, res)
 }
+
+func TestIgnoreLayers(t *testing.T) {
+	i := New(t, "integration-ignore-layers")
+
+	i.start()
+	defer i.CleanUp()
+
+	gitignore := i.createFile(".gitignore").with("*.ignored").done()
+	ignored := i.createFile("foo.ignored").done()
+	env := i.createFile(".env.local").done()
+	kept := i.createFile("kept").done()
+
+	i.apply(
+		watcher.Event{Path: gitignore, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: ignored, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: env, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: kept, Type: watcher.EventTypeAdd},
+	)
+
+	visible := []fslist.AddData{
+		{Name: i.testDir, IsDir: true},
+		{Name: kept},
+	}
+	hidden := []fslist.AddData{{Name: gitignore}, {Name: env}}
+
+	// Hidden entries are only left out when asked.
+	i.assert.ElementsMatch(append(visible, hidden...), i.getFiles(&proto.ListRequest{}))
+	i.assert.ElementsMatch(visible, i.getFiles(&proto.ListRequest{NoHidden: true}))
+
+	// Ignored entries are still indexed, so can be asked for too.
+	i.assert.ElementsMatch(append(visible, fslist.AddData{Name: ignored}),
+		i.getFiles(&proto.ListRequest{NoIgnore: true, NoHidden: true}))
+
+	counts, err := i.client.Count(context.Background(), &proto.CountRequest{NoHidden: true})
+	i.require.NoError(err)
+	i.assert.Equal(uint64(1), counts.Files)
+
+	counts, err = i.client.Count(context.Background(), &proto.CountRequest{NoIgnore: true})
+	i.require.NoError(err)
+	i.assert.Equal(uint64(4), counts.Files)
+}
+
+func TestUserIgnores(t *testing.T) {
+	i := New(t, "integration-user-ignores")
+
+	i.start()
+	defer i.CleanUp()
+
+	dist := filepath.Join(i.testDir, "dist")
+	bundle := i.createFile("dist", "bundle.js").done()
+	main := i.createFile("main.go").done()
+
+	i.apply(
+		watcher.Event{Path: dist, Type: watcher.EventTypeAdd, Dir: true},
+		watcher.Event{Path: bundle, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: main, Type: watcher.EventTypeAdd},
+	)
+
+	// Adding a pattern purges what it matches straight away.
+	ignores, err := i.client.AddIgnores(context.Background(), &proto.IgnoreRequest{Patterns: []string{"dist/"}})
+	i.require.NoError(err)
+	i.assert.Equal([]string{"dist/"}, ignores.Changed)
+	i.assert.Equal([]string{"dist/"}, ignores.Patterns)
+	i.assert.EqualValues(2, ignores.Purged)
+
+	expected := []fslist.AddData{
+		{Name: i.testDir, IsDir: true},
+		{Name: main},
+	}
+	i.assert.ElementsMatch(expected, i.getFiles(&proto.ListRequest{NoIgnore: true}))
+
+	// Nor is anything below it indexed from then on.
+	later := i.createFile("dist", "later.js").done()
+	i.apply(watcher.Event{Path: later, Type: watcher.EventTypeAdd})
+	i.assert.ElementsMatch(expected, i.getFiles(&proto.ListRequest{NoIgnore: true}))
+
+	ignores, err = i.client.AddIgnores(context.Background(), &proto.IgnoreRequest{Patterns: []string{"dist/"}})
+	i.require.NoError(err)
+	i.assert.Empty(ignores.Changed)
+
+	ignores, err = i.client.ListIgnores(context.Background(), &emptypb.Empty{})
+	i.require.NoError(err)
+	i.assert.Equal([]string{"dist/"}, ignores.Patterns)
+	i.assert.Contains(ignores.Builtin, "node_modules/")
+
+	contents, err := os.ReadFile(filepath.Join(i.tmp, "ignore"))
+	i.require.NoError(err)
+	i.assert.Equal("dist/\n", string(contents))
+
+	// Removing it picks up what is on disk again.
+	ignores, err = i.client.RemoveIgnores(context.Background(), &proto.IgnoreRequest{Patterns: []string{"dist/"}})
+	i.require.NoError(err)
+	i.assert.Equal([]string{"dist/"}, ignores.Changed)
+	i.assert.Empty(ignores.Patterns)
+
+	// That is done in the background, after the call returns.
+	letIn := append(expected,
+		fslist.AddData{Name: dist, IsDir: true},
+		fslist.AddData{Name: bundle},
+		fslist.AddData{Name: later},
+	)
+	sort.Sort(fslist.ByPath(letIn))
+	i.assert.Eventually(func() bool {
+		return assert.ObjectsAreEqual(letIn, i.getFiles(&proto.ListRequest{NoIgnore: true}))
+	}, 5*time.Second, 10*time.Millisecond)
+	i.assert.Equal(letIn, i.getFiles(&proto.ListRequest{NoIgnore: true}))
+
+	_, err = i.client.AddIgnores(context.Background(), &proto.IgnoreRequest{Patterns: []string{"# not a pattern"}})
+	i.assert.Error(err)
+}
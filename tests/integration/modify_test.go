@@ -0,0 +1,31 @@
+package integration
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
+)
+
+func TestModifyFile(t *testing.T) {
+	i := New(t, "integration-modify")
+
+	file := i.createFile("notes.txt").with("first").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	modified := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	i.require.NoError(os.WriteFile(file, []byte("second\n"), 0644))
+	i.require.NoError(os.Chtimes(file, modified, modified))
+
+	i.apply(watcher.Event{Path: file, Type: watcher.EventTypeModify})
+
+	res := i.getFilesWithTimes(&proto.ListRequest{FilesOnly: true})
+	i.require.Len(res, 1)
+	i.assert.Equal(file, res[0].Name)
+	i.require.NotNil(res[0].UpdatedAt)
+	i.assert.Equal(modified, *res[0].UpdatedAt)
+}
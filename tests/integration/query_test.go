@@ -0,0 +1,28 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/proto"
+)
+
+func TestQuery(t *testing.T) {
+	i := New(t, "integration-query")
+
+	i.createFile("fscache", "cache.go").done()
+	i.createFile("fscache", "replay.go").done()
+	i.createFile("README.md").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	i.assert.Equal([]fslist.AddData{
+		{Name: i.testDir + "/fscache/cache.go"},
+	}, i.getFiles(&proto.ListRequest{Query: "cache go"}))
+
+	i.assert.Equal([]fslist.AddData{
+		{Name: i.testDir + "/fscache/cache.go"},
+		{Name: i.testDir + "/fscache/replay.go"},
+	}, i.getFiles(&proto.ListRequest{Query: "go", FilesOnly: true}))
+}
@@ -1,53 +1,35 @@
 package integration
 
 import (
-	"context"
-	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"testing"
-	"time"
 
 	"github.com/keyneston/fscache/fslist"
 	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
 )
 
 func TestRemoveFile(t *testing.T) {
 	i := New(t, "integration-ignore")
 
-	go i.cache.Run()
-	defer i.CleanUp()
-
 	fooTXT := i.createFile("foo.txt").done()
 	barTXT := i.createFile("bar.txt").done()
 	bazTXT := i.createFile("baz.txt").done()
 
-	time.Sleep(1 * time.Second)
+	i.start()
+	defer i.CleanUp()
 
 	newBaz := filepath.Join(i.testDir, "new-baz.txt")
 	i.require.NoError(os.Remove(fooTXT), "removing file")
 	i.require.NoError(os.Rename(bazTXT, newBaz))
 
-	time.Sleep(2 * time.Second)
-
-	stream, err := i.client.GetFiles(context.Background(), &proto.ListRequest{FilesOnly: true})
-	i.require.NoError(err, "Error getting files")
+	i.apply(
+		watcher.Event{Path: fooTXT, Type: watcher.EventTypeDelete},
+		watcher.Event{Path: newBaz, OldPath: bazTXT, Type: watcher.EventTypeRename},
+	)
 
-	res := []fslist.AddData{}
-	for {
-		files, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			t.Fatalf("Error receiving files: %v", err)
-		}
-
-		for _, f := range files.Files {
-			res = append(res, fslist.AddDataFromProtoFile(f))
-		}
-	}
+	res := i.getFiles(&proto.ListRequest{FilesOnly: true})
 
 	expected := []fslist.AddData{
 		{Name: barTXT, IsDir: false},
@@ -55,9 +37,62 @@ func TestRemoveFile(t *testing.T) {
 		//{Name: i.testDir, IsDir: true},
 	}
 
-	sort.Sort(fslist.ByPath(res))
-	sort.Sort(fslist.ByPath(expected))
-
 	i.assert.Len(res, len(expected))
 	i.assert.ElementsMatch(expected, res)
 }
+
+func TestRemoveDir(t *testing.T) {
+	i := New(t, "integration-remove-dir")
+
+	keep := i.createFile("keep.txt").done()
+	i.createFile("build", "out", "bundle.js").done()
+	i.createFile("build", "out", "bundle.js.map").done()
+	i.createFile("dist", "app", "index.html").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	build := filepath.Join(i.testDir, "build")
+	dist := filepath.Join(i.testDir, "dist")
+	i.require.NoError(os.RemoveAll(build))
+	i.require.NoError(os.Rename(dist, filepath.Join(i.tmp, "dist")))
+
+	// Watchers only report the directory itself, for both a recursive
+	// delete and a move out of the root, so its children have to go along
+	// with it.
+	i.apply(
+		watcher.Event{Path: build, Type: watcher.EventTypeDelete, Dir: true},
+		watcher.Event{Path: dist, Type: watcher.EventTypeDelete, Dir: true},
+	)
+
+	expected := []fslist.AddData{
+		{Name: i.testDir, IsDir: true},
+		{Name: keep, IsDir: false},
+	}
+
+	i.assert.ElementsMatch(expected, i.getFiles(&proto.ListRequest{}))
+}
+
+func TestCreatedAndRemoved(t *testing.T) {
+	i := New(t, "integration-created-and-removed")
+
+	i.start()
+	defer i.CleanUp()
+
+	keep := i.createFile("keep.txt").done()
+	tmp := filepath.Join(i.testDir, "keep.txt.tmp")
+
+	// An editor's temporary file, which came and went within one batch.
+	i.apply(
+		watcher.Event{Path: tmp, Type: watcher.EventTypeAdd},
+		watcher.Event{Path: tmp, Type: watcher.EventTypeModify},
+		watcher.Event{Path: keep, OldPath: tmp, Type: watcher.EventTypeRename},
+	)
+
+	expected := []fslist.AddData{
+		{Name: i.testDir, IsDir: true},
+		{Name: keep, IsDir: false},
+	}
+
+	i.assert.ElementsMatch(expected, i.getFiles(&proto.ListRequest{}))
+}
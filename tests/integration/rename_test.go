@@ -0,0 +1,37 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/keyneston/fscache/fslist"
+	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
+)
+
+func TestRenameDir(t *testing.T) {
+	i := New(t, "integration-rename")
+
+	i.createFile("project", "main.go").done()
+	i.createFile("project", "pkg", "lib.go").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	project := filepath.Join(i.testDir, "project")
+	projectOld := filepath.Join(i.testDir, "project-old")
+	i.require.NoError(os.Rename(project, projectOld))
+
+	i.apply(watcher.Event{Path: projectOld, OldPath: project, Type: watcher.EventTypeRename, Dir: true})
+
+	expected := []fslist.AddData{
+		{Name: i.testDir, IsDir: true},
+		{Name: projectOld, IsDir: true},
+		{Name: filepath.Join(projectOld, "main.go"), IsDir: false},
+		{Name: filepath.Join(projectOld, "pkg"), IsDir: true},
+		{Name: filepath.Join(projectOld, "pkg", "lib.go"), IsDir: false},
+	}
+
+	i.assert.ElementsMatch(expected, i.getFiles(&proto.ListRequest{}))
+}
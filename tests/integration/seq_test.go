@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestSeq(t *testing.T) {
+	i := New(t, "integration-seq")
+
+	i.createFile("a.txt").done()
+
+	i.start()
+	defer i.CleanUp()
+
+	// seqs fetches req and returns the seq of every response.
+	seqs := func(req *proto.ListRequest) []uint64 {
+		stream, err := i.client.GetFiles(context.Background(), req)
+		i.require.NoError(err, "Error getting files")
+
+		res := []uint64{}
+		for {
+			files, err := stream.Recv()
+			if err == io.EOF {
+				return res
+			}
+			i.require.NoError(err, "Error receiving files")
+			res = append(res, files.Seq)
+		}
+	}
+
+	stats, err := i.client.GetStats(context.Background(), &emptypb.Empty{})
+	i.require.NoError(err, "Error getting stats")
+	seq := stats.Seq
+
+	i.assert.Equal([]uint64{seq, seq}, seqs(&proto.ListRequest{BatchSize: 1}))
+	i.assert.Equal([]uint64{seq}, seqs(&proto.ListRequest{Prefix: "/nothing/here"}), "empty listings have a seq too")
+
+	i.apply(watcher.Event{Path: i.createFile("b.txt").done(), Type: watcher.EventTypeAdd})
+
+	stats, err = i.client.GetStats(context.Background(), &emptypb.Empty{})
+	i.require.NoError(err, "Error getting stats")
+	i.assert.Greater(stats.Seq, seq)
+	i.assert.Equal([]uint64{stats.Seq}, seqs(&proto.ListRequest{Prefix: filepath.Join(i.testDir, "b.txt")}))
+
+	counts, err := i.client.Count(context.Background(), &proto.CountRequest{})
+	i.require.NoError(err, "Error counting")
+	i.assert.Equal(stats.Seq, counts.Seq)
+}
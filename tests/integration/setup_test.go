@@ -1,14 +1,19 @@
 package integration
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/keyneston/fscache/fscache"
+	"github.com/keyneston/fscache/fslist"
 	"github.com/keyneston/fscache/internal/shared"
 	"github.com/keyneston/fscache/proto"
+	"github.com/keyneston/fscache/watcher"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +30,7 @@ type integration struct {
 	assert  *assert.Assertions
 	cache   *fscache.FSCache
 	client  proto.FSCacheClient
+	watcher *watcher.Fake
 
 	name      string
 	testDir   string
@@ -47,11 +53,14 @@ func New(t *testing.T, name string) *integration {
 
 	socketLoc := filepath.Join(tmp, "socket")
 	testDir := filepath.Join(tmp, "test")
+	require.NoError(os.Mkdir(testDir, 0755), "Error creating test dir")
 
+	fake := watcher.NewFake()
 	cache, err := fscache.New(
 		socketLoc,
 		testDir,
 		"pebble",
+		fscache.Options{Source: fake, UserIgnoreFile: filepath.Join(tmp, "ignore")},
 	)
 	require.NoError(err, "Error creating fscache")
 
@@ -68,15 +77,63 @@ func New(t *testing.T, name string) *integration {
 		testDir:   testDir,
 		socketLoc: socketLoc,
 		client:    client,
+		watcher:   fake,
 	}
 }
 
+// start runs the cache and waits for the initial walk of the test dir to
+// finish.
+func (i *integration) start() {
+	go i.cache.Run()
+	i.watcher.Sync()
+}
+
+// apply sends events as a single batch from the watcher, and waits for the
+// cache to apply them.
+func (i *integration) apply(events ...watcher.Event) {
+	i.watcher.Send(events...)
+	i.watcher.Sync()
+}
+
 func (i *integration) CleanUp() {
 	i.cache.Close()
 	err := os.RemoveAll(i.tmp)
 	i.require.NoError(err, "integration.CleanUp")
 }
 
+// getFiles fetches everything matching req from the cache, sorted by path.
+// UpdatedAt is dropped so the results can be compared by path alone, use
+// getFilesWithTimes to keep it.
+func (i *integration) getFiles(req *proto.ListRequest) []fslist.AddData {
+	res := i.getFilesWithTimes(req)
+	for j := range res {
+		res[j].UpdatedAt = nil
+	}
+
+	return res
+}
+
+func (i *integration) getFilesWithTimes(req *proto.ListRequest) []fslist.AddData {
+	stream, err := i.client.GetFiles(context.Background(), req)
+	i.require.NoError(err, "Error getting files")
+
+	res := []fslist.AddData{}
+	for {
+		files, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		i.require.NoError(err, "Error receiving files")
+
+		for _, f := range files.Files {
+			res = append(res, fslist.AddDataFromProtoFile(f))
+		}
+	}
+
+	sort.Sort(fslist.ByPath(res))
+	return res
+}
+
 func (i *integration) createFile(pathSegments ...string) createFile {
 	return createFile{
 		path: filepath.Join(append([]string{i.testDir}, pathSegments...)...),
@@ -0,0 +1,233 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var DefaultCoalesceWindow = time.Millisecond * 250
+
+var _ Watcher = &Coalescer{}
+
+// Coalescer wraps a Watcher and collapses its events before passing them on.
+// Events are held for a window, then for each path only the net change is
+// kept: a file created and removed again within the window becomes a single
+// delete, and repeated writes to a file become a single modify. Events
+// below a directory that was deleted are dropped, and those below a newly
+// created directory are replaced with a single rescan of it.
+//
+// Renames and rescans depend on what came before them, so they aren't merged
+// and events are only coalesced between them.
+type Coalescer struct {
+	watcher Watcher
+	window  time.Duration
+
+	received uint64
+	emitted  uint64
+
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+}
+
+// CoalesceStats counts the events going through a Coalescer.
+type CoalesceStats struct {
+	Received uint64
+	Emitted  uint64
+}
+
+// NewCoalescer creates a Coalescer holding events from w for window. A window
+// of 0 only coalesces within each batch from w.
+func NewCoalescer(w Watcher, window time.Duration) *Coalescer {
+	return &Coalescer{
+		watcher:   w,
+		window:    window,
+		closeCh:   make(chan bool),
+		closeOnce: &sync.Once{},
+		stream:    make(chan []Event, 10),
+	}
+}
+
+func (c *Coalescer) Start() {
+	c.watcher.Start()
+
+	go c.run()
+}
+
+func (c *Coalescer) run() {
+	pending := []Event{}
+
+	// flush is nil, and so never fires, while nothing is pending.
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case events := <-c.watcher.Stream():
+			atomic.AddUint64(&c.received, uint64(len(events)))
+			pending = append(pending, events...)
+
+			if c.window <= 0 {
+				pending = c.send(pending)
+			} else if flush == nil {
+				flush = time.After(c.window)
+			}
+		case <-flush:
+			flush = nil
+			pending = c.send(pending)
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+// send coalesces and sends events, returning an empty slice to collect the
+// next window in.
+func (c *Coalescer) send(events []Event) []Event {
+	coalesced := Coalesce(events)
+	atomic.AddUint64(&c.emitted, uint64(len(coalesced)))
+
+	if len(coalesced) != 0 {
+		select {
+		case c.stream <- coalesced:
+		case <-c.closeCh:
+		}
+	}
+
+	return []Event{}
+}
+
+func (c *Coalescer) Stats() CoalesceStats {
+	return CoalesceStats{
+		Received: atomic.LoadUint64(&c.received),
+		Emitted:  atomic.LoadUint64(&c.emitted),
+	}
+}
+
+func (c *Coalescer) Stop() {
+	c.closeOnce.Do(func() {
+		c.watcher.Stop()
+		close(c.closeCh)
+	})
+}
+
+func (c *Coalescer) Stream() <-chan []Event {
+	return c.stream
+}
+
+// Coalesce reduces events to the smallest list of events with the same end
+// result.
+func Coalesce(events []Event) []Event {
+	res := []Event{}
+
+	start := 0
+	for i, e := range events {
+		switch e.Type {
+		case EventTypeAdd, EventTypeDelete, EventTypeModify:
+			continue
+		}
+
+		res = append(res, coalesceRun(events[start:i])...)
+		res = append(res, e)
+		start = i + 1
+	}
+
+	return append(res, coalesceRun(events[start:])...)
+}
+
+// pathHistory is the first and last event seen for a path.
+type pathHistory struct {
+	first, last Event
+	index       int
+	// removed is set if the path was deleted at any point.
+	removed bool
+}
+
+// coalesceRun coalesces a run of add, delete and modify events.
+func coalesceRun(events []Event) []Event {
+	if len(events) < 2 {
+		return events
+	}
+
+	histories := map[string]*pathHistory{}
+	for i, e := range events {
+		if h, ok := histories[e.Path]; ok {
+			h.last = e
+			h.index = i
+			h.removed = h.removed || e.Type == EventTypeDelete
+		} else {
+			histories[e.Path] = &pathHistory{first: e, last: e, index: i, removed: e.Type == EventTypeDelete}
+		}
+	}
+
+	// Results are ordered by each path's last event. The only way events for
+	// different paths interact is a directory delete removing its children,
+	// and that ordering keeps a child re-created afterwards.
+	ordered := make([]*pathHistory, 0, len(histories))
+	for _, h := range histories {
+		ordered = append(ordered, h)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].index < ordered[j].index })
+
+	rescans := map[string]bool{}
+	res := []Event{}
+	for _, h := range ordered {
+		// A path which came and went within the window still gets its
+		// delete, as the add may have been something moved over an
+		// existing, indexed, path.
+		created := h.first.Type == EventTypeAdd
+		deleted := h.last.Type == EventTypeDelete
+
+		if dir, ok := findAncestor(histories, h.last.Path); ok {
+			if dir.last.Type == EventTypeAdd {
+				rescans[dir.last.Path] = true
+			}
+			continue
+		}
+
+		last := h.last
+		if created && last.Type == EventTypeModify {
+			// Writes to a new file are part of creating it.
+			last.Type = EventTypeAdd
+		}
+
+		if !created && !deleted && h.removed && (h.first.Dir || last.Dir) {
+			// Replaced, and a directory delete is needed to clear out what
+			// was below the old one.
+			res = append(res, Event{Path: h.first.Path, Type: EventTypeDelete, Dir: h.first.Dir})
+		}
+		res = append(res, last)
+	}
+
+	for i, e := range res {
+		if rescans[e.Path] {
+			res[i] = Event{Path: e.Path, Type: EventTypeRescan, Dir: true}
+		}
+	}
+
+	return res
+}
+
+// findAncestor finds the outermost directory above path that was either
+// created or deleted by the end of the run. Anything below it is covered by
+// that directory's own event.
+func findAncestor(histories map[string]*pathHistory, path string) (*pathHistory, bool) {
+	var found *pathHistory
+
+	for dir := filepath.Dir(path); dir != path; path, dir = dir, filepath.Dir(dir) {
+		h, ok := histories[dir]
+		if !ok || !h.last.Dir {
+			continue
+		}
+
+		created := h.first.Type == EventTypeAdd
+		deleted := h.last.Type == EventTypeDelete
+		if created || deleted {
+			found = h
+		}
+	}
+
+	return found, found != nil
+}
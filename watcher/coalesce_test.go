@@ -0,0 +1,126 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	add := func(path string, dir bool) Event { return Event{Path: path, Type: EventTypeAdd, Dir: dir} }
+	del := func(path string, dir bool) Event { return Event{Path: path, Type: EventTypeDelete, Dir: dir} }
+	mod := func(path string) Event { return Event{Path: path, Type: EventTypeModify} }
+	rescan := func(path string) Event { return Event{Path: path, Type: EventTypeRescan, Dir: true} }
+	rename := func(from, to string) Event { return Event{Path: to, OldPath: from, Type: EventTypeRename} }
+
+	type testCase struct {
+		name     string
+		input    []Event
+		expected []Event
+	}
+
+	testCases := []testCase{
+		{
+			name:     "created_and_removed",
+			input:    []Event{add("/a/tmp", false), del("/a/tmp", false)},
+			expected: []Event{del("/a/tmp", false)},
+		},
+		{
+			// The add is all a move over an existing path reports.
+			name:     "moved_over_existing_and_removed",
+			input:    []Event{add("/a/existing", false), mod("/a/existing"), del("/a/existing", false)},
+			expected: []Event{del("/a/existing", false)},
+		},
+		{
+			name: "created_dir_removed_with_children",
+			input: []Event{
+				add("/a/new", true),
+				add("/a/new/x", false),
+				del("/a/new/x", false),
+				del("/a/new", true),
+			},
+			expected: []Event{del("/a/new", true)},
+		},
+		{
+			name:     "add_delete_add",
+			input:    []Event{add("/a/f", false), del("/a/f", false), add("/a/f", false)},
+			expected: []Event{add("/a/f", false)},
+		},
+		{
+			name:     "deleted_and_recreated_file",
+			input:    []Event{del("/a/f", false), add("/a/f", false)},
+			expected: []Event{add("/a/f", false)},
+		},
+		{
+			name:     "repeated_writes",
+			input:    []Event{mod("/a/f"), mod("/a/f"), mod("/a/f")},
+			expected: []Event{mod("/a/f")},
+		},
+		{
+			name:     "created_and_written",
+			input:    []Event{add("/a/f", false), mod("/a/f")},
+			expected: []Event{add("/a/f", false)},
+		},
+		{
+			name:     "written_and_removed",
+			input:    []Event{mod("/a/f"), del("/a/f", false)},
+			expected: []Event{del("/a/f", false)},
+		},
+		{
+			name:     "written_file_replaced_by_dir",
+			input:    []Event{mod("/a/f"), del("/a/f", false), add("/a/f", true)},
+			expected: []Event{del("/a/f", false), add("/a/f", true)},
+		},
+		{
+			name:     "file_replaced_by_dir",
+			input:    []Event{del("/a/f", false), add("/a/f", true)},
+			expected: []Event{del("/a/f", false), add("/a/f", true)},
+		},
+		{
+			name: "children_of_deleted_dir",
+			input: []Event{
+				del("/a/build/1.o", false),
+				del("/a/build/sub", true),
+				del("/a/build", true),
+				add("/a/other", false),
+			},
+			expected: []Event{del("/a/build", true), add("/a/other", false)},
+		},
+		{
+			name: "children_of_created_dir",
+			input: []Event{
+				add("/a/new", true),
+				add("/a/new/sub", true),
+				add("/a/new/sub/1.txt", false),
+				del("/a/new/sub/1.txt", false),
+				add("/a/new/2.txt", false),
+			},
+			expected: []Event{rescan("/a/new")},
+		},
+		{
+			name: "child_recreated_after_dir_replaced",
+			input: []Event{
+				add("/a/d/x", false),
+				del("/a/d", true),
+				add("/a/d", true),
+				add("/a/d/x", false),
+			},
+			expected: []Event{del("/a/d", true), add("/a/d", true), add("/a/d/x", false)},
+		},
+		{
+			name: "rename_is_a_barrier",
+			input: []Event{
+				add("/a/f", false),
+				rename("/a/f", "/a/g"),
+				del("/a/g", false),
+			},
+			expected: []Event{add("/a/f", false), rename("/a/f", "/a/g"), del("/a/g", false)},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, Coalesce(c.input))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package watcher
+
+import "sync"
+
+var _ Watcher = &Fake{}
+
+// Fake is a Watcher which only emits the events it is given. It lets tests
+// script exactly what the watcher reports, without touching the filesystem or
+// waiting for the kernel.
+type Fake struct {
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		closeCh:   make(chan bool),
+		closeOnce: &sync.Once{},
+		stream:    make(chan []Event),
+	}
+}
+
+func (f *Fake) Start() {}
+
+// Send emits events as a single batch. It blocks until the batch is received
+// or the watcher is stopped.
+func (f *Fake) Send(events ...Event) {
+	select {
+	case f.stream <- events:
+	case <-f.closeCh:
+	}
+}
+
+// Sync blocks until everything sent before it has been applied, by sending an
+// EventTypeSync and waiting for it to be acknowledged. It returns early if the
+// watcher is stopped.
+func (f *Fake) Sync() {
+	ack := make(chan struct{})
+	f.Send(Event{Type: EventTypeSync, ack: ack})
+
+	select {
+	case <-ack:
+	case <-f.closeCh:
+	}
+}
+
+func (f *Fake) Stop() {
+	f.closeOnce.Do(func() {
+		close(f.closeCh)
+	})
+}
+
+func (f *Fake) Stream() <-chan []Event {
+	return f.stream
+}
@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeSync(t *testing.T) {
+	f := NewFake()
+	c := NewCoalescer(f, 0)
+	c.Start()
+	defer c.Stop()
+
+	applied := []Event{}
+	go func() {
+		for events := range c.Stream() {
+			for _, e := range events {
+				if e.Type == EventTypeSync {
+					e.Ack()
+					continue
+				}
+				applied = append(applied, e)
+			}
+		}
+	}()
+
+	f.Send(
+		Event{Path: "/a/tmp", Type: EventTypeAdd},
+		Event{Path: "/a/tmp", Type: EventTypeDelete},
+		Event{Path: "/a/f", Type: EventTypeAdd},
+	)
+	f.Send(Event{Path: "/a/f", Type: EventTypeModify})
+	f.Sync()
+
+	// Without a window each batch is coalesced on its own.
+	assert.Equal(t, []Event{
+		{Path: "/a/tmp", Type: EventTypeDelete},
+		{Path: "/a/f", Type: EventTypeAdd},
+		{Path: "/a/f", Type: EventTypeModify},
+	}, applied)
+}
@@ -0,0 +1,22 @@
+package watcher
+
+import "github.com/keyneston/fscache/internal/shared"
+
+// openFanotify tries to create a fanotify watcher. fanotify needs a recent
+// kernel and CAP_SYS_ADMIN, so when it can't be set up the platform default is
+// used instead. fanotify reports the real path of every event, so it is also
+// skipped when following symlinks.
+func openFanotify(root string, opts Options) (Watcher, error) {
+	if opts.FollowSymlinks {
+		shared.Logger().Warn().Msg("fanotify can't follow symlinks, falling back to default watcher")
+		return openDefault(root, opts)
+	}
+
+	w, err := newFanotify(root, opts.Boundary)
+	if err != nil {
+		shared.Logger().Warn().Err(err).Msg("unable to use fanotify, falling back to default watcher")
+		return openDefault(root, opts)
+	}
+
+	return w, nil
+}
@@ -0,0 +1,462 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/internal/walk"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
+)
+
+const fanotifyMask = unix.FAN_CREATE | unix.FAN_DELETE | unix.FAN_MOVED_FROM |
+	unix.FAN_MOVED_TO | unix.FAN_CLOSE_WRITE | unix.FAN_ONDIR
+
+// fanotifyInfoHeaderLen is the size of the fanotify_event_info_fid header
+// preceding the file handle: the info header (type, pad, len) and the fsid.
+const fanotifyInfoHeaderLen = 4 + 8
+
+// fileHandleHeaderLen is the size of struct file_handle without f_handle.
+const fileHandleHeaderLen = 4 + 4
+
+// NewFanotify creates a watcher which marks the whole filesystem containing
+// root, and any filesystems mounted below it, rather than each directory.
+// This avoids the per-directory watch limits of inotify at the cost of
+// needing CAP_SYS_ADMIN, to mark filesystems, and CAP_DAC_READ_SEARCH, to turn
+// the reported directory handles back into paths.
+//
+// Handles are resolved when the event is read. If the parent directory is
+// already gone by then, as happens to the children during `rm -rf`, the event
+// can't be placed and is dropped.
+func NewFanotify(root string) (Watcher, error) {
+	return newFanotify(root, nil)
+}
+
+func newFanotify(root string, boundary *walk.Boundary) (Watcher, error) {
+	fd, err := unix.FanotifyInit(
+		unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK|unix.FAN_REPORT_DFID_NAME,
+		unix.O_RDONLY|unix.O_CLOEXEC,
+	)
+	if err != nil {
+		return nil, os.NewSyscallError("fanotify_init", err)
+	}
+
+	logger := shared.Logger().With().Str("module", "fanotify_linux").Logger()
+
+	root = filepath.Clean(root)
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	w := &FanotifyWatcher{
+		root:       root,
+		resolved:   resolved,
+		boundary:   boundary,
+		fd:         fd,
+		file:       os.NewFile(uintptr(fd), "fanotify"),
+		mounts:     map[unix.Fsid]string{},
+		openMounts: map[unix.Fsid]int{},
+		closeCh:    make(chan bool),
+		closeOnce:  &sync.Once{},
+		stream:     make(chan []Event, 10),
+		logger:     &logger,
+	}
+
+	if err := w.init(); err != nil {
+		w.Stop()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+type FanotifyWatcher struct {
+	root string
+	// resolved is root with any symlinks resolved. Paths from handles are
+	// always fully resolved, so are matched against this then moved back
+	// under root to match the index.
+	resolved string
+	boundary *walk.Boundary
+	fd       int
+	file     *os.File
+
+	// lock covers mounts and openMounts, which are used by the run goroutine
+	// and updated when filesystems are mounted.
+	lock sync.Mutex
+	// mounts holds a directory on each marked filesystem, which
+	// open_by_handle_at needs to decode handles from that filesystem.
+	mounts map[unix.Fsid]string
+	// openMounts are the directories from mounts opened while handling a
+	// batch of events. They are closed after every batch, as an open
+	// directory would stop its filesystem from being unmounted.
+	openMounts map[unix.Fsid]int
+
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+
+	logger *zerolog.Logger
+}
+
+// init marks root's filesystem and those mounted below it, then checks that
+// handles can be resolved, so a missing capability is reported up front
+// instead of on every event.
+func (w *FanotifyWatcher) init() error {
+	if err := w.mark(w.root); err != nil {
+		return err
+	}
+
+	mounts, err := mountsUnder(w.resolved)
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("unable to list mounts, only watching the root filesystem")
+	}
+
+	for _, m := range mounts {
+		if w.excluded(m) {
+			w.logger.Debug().Str("mount", m).Msg("mount excluded, skipping")
+			continue
+		}
+
+		if err := w.mark(m); err != nil {
+			w.logger.Debug().Err(err).Str("mount", m).Msg("unable to mark mount, skipping")
+		}
+	}
+
+	handle, _, err := unix.NameToHandleAt(unix.AT_FDCWD, w.root, 0)
+	if err != nil {
+		return os.NewSyscallError("name_to_handle_at", err)
+	}
+
+	var stat unix.Statfs_t
+	if err := unix.Statfs(w.root, &stat); err != nil {
+		return os.NewSyscallError("statfs", err)
+	}
+
+	_, err = w.resolve(stat.Fsid, handle)
+	w.closeMounts()
+	return err
+}
+
+func (w *FanotifyWatcher) excluded(path string) bool {
+	d, err := walk.Entry(path, false)
+	return err == nil && w.boundary.Excluded(path, d)
+}
+
+func (w *FanotifyWatcher) mark(path string) error {
+	flags := uint(unix.FAN_MARK_ADD | unix.FAN_MARK_FILESYSTEM)
+	if err := unix.FanotifyMark(w.fd, flags, fanotifyMask, unix.AT_FDCWD, path); err != nil {
+		return os.NewSyscallError("fanotify_mark", err)
+	}
+
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return os.NewSyscallError("statfs", err)
+	}
+
+	if _, ok := w.mounts[stat.Fsid]; !ok {
+		w.mounts[stat.Fsid] = path
+	}
+
+	return nil
+}
+
+func (w *FanotifyWatcher) Start() {
+	go w.run()
+	go newMountWatcher(w.resolved).run(w.closeCh, w.remount)
+}
+
+// underRoot moves a resolved path below w.resolved to the same place below
+// w.root. It returns false if path isn't below w.resolved.
+func (w *FanotifyWatcher) underRoot(path string) (string, bool) {
+	if !isUnder(w.resolved, path) {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(w.resolved, path)
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Join(w.root, rel), true
+}
+
+// remount marks a filesystem newly mounted at path, and rescans path so the
+// index matches whatever is there now.
+func (w *FanotifyWatcher) remount(path string) {
+	if !w.excluded(path) {
+		w.lock.Lock()
+		if err := w.mark(path); err != nil {
+			w.logger.Debug().Err(err).Str("mount", path).Msg("unable to mark mount, skipping")
+		}
+		w.lock.Unlock()
+	}
+
+	path, ok := w.underRoot(path)
+	if !ok {
+		return
+	}
+
+	select {
+	case w.stream <- []Event{{Path: path, Type: EventTypeRescan, Dir: true}}:
+	case <-w.closeCh:
+	}
+}
+
+func (w *FanotifyWatcher) run() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.logger.Error().Err(err).Msg("error reading fanotify events")
+			}
+			return
+		}
+
+		w.handleEvents(buf[:n])
+	}
+}
+
+func (w *FanotifyWatcher) handleEvents(buf []byte) {
+	// The lock is only held while translating, so a slow reader of the
+	// stream doesn't hold up marking new mounts.
+	translated := w.translateAll(buf)
+	if len(translated) == 0 {
+		return
+	}
+
+	select {
+	case w.stream <- translated:
+	case <-w.closeCh:
+	}
+}
+
+// translateAll translates every event in buf.
+func (w *FanotifyWatcher) translateAll(buf []byte) []Event {
+	translated := []Event{}
+
+	w.lock.Lock()
+	defer w.closeMounts()
+	defer w.lock.Unlock()
+
+	for len(buf) >= unix.FAN_EVENT_METADATA_LEN {
+		meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[0]))
+		if meta.Event_len < unix.FAN_EVENT_METADATA_LEN || int(meta.Event_len) > len(buf) {
+			w.logger.Error().Uint32("len", meta.Event_len).Msg("malformed fanotify event")
+			break
+		}
+		if meta.Vers != unix.FANOTIFY_METADATA_VERSION {
+			w.logger.Error().Uint8("version", meta.Vers).Msg("unsupported fanotify metadata version")
+			break
+		}
+
+		translated = append(translated, w.translate(meta, buf[meta.Metadata_len:meta.Event_len])...)
+		buf = buf[meta.Event_len:]
+	}
+
+	return translated
+}
+
+func (w *FanotifyWatcher) translate(meta *unix.FanotifyEventMetadata, info []byte) []Event {
+	mask := meta.Mask
+
+	if mask&unix.FAN_Q_OVERFLOW != 0 {
+		w.logger.Error().Str("root", w.root).Msg("fanotify queue overflowed, rescanning")
+		return []Event{{Path: w.root, Type: EventTypeRescan, Dir: true}}
+	}
+
+	dir, name, err := w.parseInfo(info)
+	if err != nil {
+		w.logger.Debug().Err(err).Uint64("mask", mask).Msg("unable to resolve event, skipping")
+		return nil
+	}
+
+	// The whole filesystem is marked, so most events will be for paths
+	// outside of root.
+	path, ok := w.underRoot(filepath.Join(dir, name))
+	if !ok {
+		return nil
+	}
+
+	e := Event{
+		Path: path,
+		Dir:  mask&unix.FAN_ONDIR != 0,
+	}
+
+	added := mask&(unix.FAN_CREATE|unix.FAN_MOVED_TO) != 0
+	removed := mask&(unix.FAN_DELETE|unix.FAN_MOVED_FROM) != 0
+
+	// fanotify merges queued events for the same name, so a create and
+	// delete can arrive together. Whatever is on disk now wins.
+	if added && removed {
+		_, err := os.Lstat(e.Path)
+		added = err == nil
+		removed = !added
+	}
+
+	switch {
+	case added:
+		w.logger.Trace().Str("path", e.Path).Uint64("mask", mask).Msg("created")
+		e.Type = EventTypeAdd
+
+		// Unlike a created directory, one moved in from elsewhere already
+		// has contents that won't generate events of their own.
+		if e.Dir && mask&unix.FAN_MOVED_TO != 0 {
+			return append([]Event{e}, w.walk(e.Path)...)
+		}
+	case removed:
+		w.logger.Trace().Str("path", e.Path).Uint64("mask", mask).Msg("removed")
+		e.Type = EventTypeDelete
+	case mask&unix.FAN_CLOSE_WRITE != 0:
+		w.logger.Trace().Str("path", e.Path).Uint64("mask", mask).Msg("modified")
+		e.Type = EventTypeModify
+	default:
+		w.logger.Warn().Str("path", e.Path).Uint64("mask", mask).Msg("Unknown event type, skipping")
+		return nil
+	}
+
+	return []Event{e}
+}
+
+// parseInfo finds the directory handle and name record following the event
+// metadata and resolves it to the directory path and entry name.
+func (w *FanotifyWatcher) parseInfo(info []byte) (string, string, error) {
+	for len(info) >= 4 {
+		infoType := info[0]
+		infoLen := int(*(*uint16)(unsafe.Pointer(&info[2])))
+		if infoLen < 4 || infoLen > len(info) {
+			return "", "", fmt.Errorf("malformed info record of length %d", infoLen)
+		}
+
+		record := info[:infoLen]
+		info = info[infoLen:]
+
+		if infoType != unix.FAN_EVENT_INFO_TYPE_DFID_NAME {
+			continue
+		}
+
+		if len(record) < fanotifyInfoHeaderLen+fileHandleHeaderLen {
+			return "", "", errors.New("truncated file handle")
+		}
+
+		fsid := *(*unix.Fsid)(unsafe.Pointer(&record[4]))
+		handleLen := int(*(*uint32)(unsafe.Pointer(&record[fanotifyInfoHeaderLen])))
+		handleType := *(*int32)(unsafe.Pointer(&record[fanotifyInfoHeaderLen+4]))
+
+		start := fanotifyInfoHeaderLen + fileHandleHeaderLen
+		if start+handleLen > len(record) {
+			return "", "", errors.New("truncated file handle")
+		}
+
+		name := record[start+handleLen:]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+
+		dir, err := w.resolve(fsid, unix.NewFileHandle(handleType, record[start:start+handleLen]))
+		return dir, string(name), err
+	}
+
+	return "", "", errors.New("no directory handle in event")
+}
+
+// resolve turns a file handle back into a path.
+func (w *FanotifyWatcher) resolve(fsid unix.Fsid, handle unix.FileHandle) (string, error) {
+	mountFd, err := w.openMount(fsid)
+	if err != nil {
+		return "", err
+	}
+
+	fd, err := unix.OpenByHandleAt(mountFd, handle, unix.O_PATH|unix.O_CLOEXEC)
+	if err != nil {
+		return "", os.NewSyscallError("open_by_handle_at", err)
+	}
+	defer unix.Close(fd)
+
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(path, " (deleted)") {
+		return "", fmt.Errorf("directory %q has been deleted", strings.TrimSuffix(path, " (deleted)"))
+	}
+
+	return path, nil
+}
+
+func (w *FanotifyWatcher) openMount(fsid unix.Fsid) (int, error) {
+	if fd, ok := w.openMounts[fsid]; ok {
+		return fd, nil
+	}
+
+	path, ok := w.mounts[fsid]
+	if !ok {
+		return -1, fmt.Errorf("event for unmarked filesystem %v", fsid.Val)
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, os.NewSyscallError("open", err)
+	}
+
+	w.openMounts[fsid] = fd
+	return fd, nil
+}
+
+func (w *FanotifyWatcher) closeMounts() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for fsid, fd := range w.openMounts {
+		unix.Close(fd)
+		delete(w.openMounts, fsid)
+	}
+}
+
+// walk returns an add Event for everything below root.
+func (w *FanotifyWatcher) walk(root string) []Event {
+	events := []Event{}
+
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			w.logger.Debug().Err(err).Str("path", path).Msg("error walking, skipping")
+			return nil
+		}
+
+		if path != root {
+			events = append(events, Event{Path: path, Type: EventTypeAdd, Dir: d.IsDir()})
+		}
+
+		if w.boundary.Excluded(path, d) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	return events
+}
+
+func (w *FanotifyWatcher) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+		w.file.Close()
+	})
+}
+
+func (w *FanotifyWatcher) Stream() <-chan []Event {
+	return w.stream
+}
@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanotifyWatcher(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "fanotify-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	tmp, err = filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	w, err := NewFanotify(tmp)
+	if err != nil {
+		t.Skipf("fanotify unavailable: %v", err)
+	}
+	w.Start()
+	defer w.Stop()
+
+	dir := filepath.Join(tmp, "dir")
+	file := filepath.Join(dir, "file.txt")
+
+	require.NoError(t, os.Mkdir(dir, 0755))
+	expectEvents(t, w, Event{Path: dir, Type: EventTypeAdd, Dir: true})
+
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeAdd})
+
+	require.NoError(t, os.WriteFile(file, []byte("more data"), 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeModify})
+
+	require.NoError(t, os.Remove(file))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeDelete})
+}
+
+func TestFanotifyWatcherSymlinkedRoot(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "fanotify-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	real := filepath.Join(tmp, "real")
+	require.NoError(t, os.Mkdir(real, 0755))
+
+	// Events come back fully resolved, but must still be reported below the
+	// root the watcher was given.
+	root := filepath.Join(tmp, "link")
+	require.NoError(t, os.Symlink(real, root))
+
+	w, err := NewFanotify(root)
+	if err != nil {
+		t.Skipf("fanotify unavailable: %v", err)
+	}
+	w.Start()
+	defer w.Stop()
+
+	dir := filepath.Join(root, "dir")
+	file := filepath.Join(dir, "file.txt")
+
+	require.NoError(t, os.Mkdir(filepath.Join(real, "dir"), 0755))
+	expectEvents(t, w, Event{Path: dir, Type: EventTypeAdd, Dir: true})
+
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeAdd})
+
+	require.NoError(t, os.Remove(file))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeDelete})
+}
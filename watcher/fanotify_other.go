@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package watcher
+
+import (
+	"errors"
+
+	"github.com/keyneston/fscache/internal/walk"
+)
+
+func NewFanotify(root string) (Watcher, error) {
+	return newFanotify(root, nil)
+}
+
+func newFanotify(root string, boundary *walk.Boundary) (Watcher, error) {
+	return nil, errors.New("fanotify is only supported on linux")
+}
@@ -1,3 +1,4 @@
+//go:build darwin
 // +build darwin
 
 package watcher
@@ -24,6 +25,16 @@ func New(root string) (Watcher, error) {
 	}, nil
 }
 
+// openDefault creates the fsevents watcher. fsevents reports the real path of
+// every event, so symlinks can't be followed.
+func openDefault(root string, opts Options) (Watcher, error) {
+	if opts.FollowSymlinks {
+		shared.Logger().Warn().Msg("fsevents can't follow symlinks, ignoring")
+	}
+
+	return New(root)
+}
+
 type DarwinWatcher struct {
 	eventStream *fsevents.EventStream
 	closeCh     chan bool
@@ -53,9 +64,41 @@ func (d *DarwinWatcher) handleEvents(events []fsevents.Event) {
 
 	logger.Trace().Interface("events", events).Msg("got events")
 	translated := []Event{}
-	for _, e := range events {
+	for i := 0; i < len(events); i++ {
+		e := events[i]
 		t := Event{Path: e.Path}
 
+		// Events below this path were coalesced or dropped, either by the
+		// kernel or because we didn't keep up.
+		if checkBitFlag(e.Flags, fsevents.MustScanSubDirs) {
+			logger.Warn().Interface("event", e).Strs("flags", flagsToStrings(e.Flags)).Msg("MustScanSubDirs, rescanning")
+			translated = append(translated, Event{Path: e.Path, Type: EventTypeRescan, Dir: true})
+			continue
+		}
+
+		// Whatever was known below a mount point was for the filesystem
+		// that used to be there.
+		if checkBitFlag(e.Flags, fsevents.Mount) || checkBitFlag(e.Flags, fsevents.Unmount) {
+			logger.Info().Str("path", e.Path).Strs("flags", flagsToStrings(e.Flags)).Msg("mount changed, rescanning")
+			translated = append(translated, Event{Path: e.Path, Type: EventTypeRescan, Dir: true})
+			continue
+		}
+
+		// A rename arrives as a pair of ItemRenamed events with consecutive
+		// IDs, the old path followed by the new one.
+		if i+1 < len(events) && isRenamePair(e, events[i+1]) {
+			next := events[i+1]
+			logger.Trace().Str("from", e.Path).Str("to", next.Path).Msg("ItemRenamed pair")
+			translated = append(translated, Event{
+				Path:    next.Path,
+				OldPath: e.Path,
+				Type:    EventTypeRename,
+				Dir:     checkBitFlag(next.Flags, fsevents.ItemIsDir),
+			})
+			i++
+			continue
+		}
+
 		switch {
 		// ItemRenamed needs to be first, that way we can check if the file
 		// exists or not and use that for determaining whether it was
@@ -74,9 +117,9 @@ func (d *DarwinWatcher) handleEvents(events []fsevents.Event) {
 		case checkBitFlag(e.Flags, fsevents.ItemCreated):
 			logger.Trace().Interface("event", e).Strs("flags", flagsToStrings(e.Flags)).Msg("ItemCreated")
 			t.Type = EventTypeAdd
-		case checkBitFlag(e.Flags, fsevents.MustScanSubDirs):
-			logger.Warn().Interface("event", e).Msg("MustScanSubDirs, skipping")
-			continue
+		case checkBitFlag(e.Flags, fsevents.ItemModified):
+			logger.Trace().Interface("event", e).Strs("flags", flagsToStrings(e.Flags)).Msg("ItemModified")
+			t.Type = EventTypeModify
 		}
 
 		if t.Type == EventUnknown {
@@ -93,6 +136,19 @@ func (d *DarwinWatcher) handleEvents(events []fsevents.Event) {
 	}
 }
 
+func isRenamePair(from, to fsevents.Event) bool {
+	if !checkBitFlag(from.Flags, fsevents.ItemRenamed) || !checkBitFlag(to.Flags, fsevents.ItemRenamed) {
+		return false
+	}
+	if to.ID != from.ID+1 {
+		return false
+	}
+
+	_, fromErr := os.Lstat(from.Path)
+	_, toErr := os.Lstat(to.Path)
+	return fromErr != nil && toErr == nil
+}
+
 func (d *DarwinWatcher) Stop() {
 	d.closeOnce.Do(func() {
 		d.eventStream.Stop()
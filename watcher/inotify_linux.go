@@ -0,0 +1,394 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/internal/walk"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask is the set of events registered on every watched directory.
+// IN_CLOSE_WRITE is used for modifications rather than IN_MODIFY, which fires
+// on every write.
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_CLOSE_WRITE | unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW
+
+func New(root string) (Watcher, error) {
+	return newInotify(root, Options{})
+}
+
+func openDefault(root string, opts Options) (Watcher, error) {
+	return newInotify(root, opts)
+}
+
+func newInotify(root string, opts Options) (*InotifyWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	logger := shared.Logger().With().Str("module", "inotify_linux").Logger()
+
+	return &InotifyWatcher{
+		root:     root,
+		follow:   opts.FollowSymlinks,
+		boundary: opts.Boundary,
+		fd:       fd,
+		// Wrapping the non-blocking descriptor in an os.File hands it to the
+		// runtime poller, which lets Stop interrupt a pending Read by closing
+		// the file.
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		watches:   map[int]string{},
+		paths:     map[string]int{},
+		closeCh:   make(chan bool),
+		closeOnce: &sync.Once{},
+		stream:    make(chan []Event, 10),
+		logger:    &logger,
+	}, nil
+}
+
+// InotifyWatcher recursively watches a directory tree using inotify. inotify
+// only reports on the direct children of a watched directory, so every
+// directory under root gets its own watch and new directories are registered
+// as they appear.
+//
+// When following symlinks the directories they point to are watched through
+// the link's path, so their events are reported under it. A directory reached
+// through more than one link only reports under the last one found.
+type InotifyWatcher struct {
+	root     string
+	follow   bool
+	boundary *walk.Boundary
+	fd       int
+	file     *os.File
+
+	// lock covers watches and paths, which are used by the run goroutine and
+	// updated when filesystems are mounted.
+	lock    sync.Mutex
+	watches map[int]string
+	paths   map[string]int
+
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+
+	logger *zerolog.Logger
+}
+
+func (w *InotifyWatcher) Start() {
+	w.lock.Lock()
+	w.addRecursive(w.root, false)
+	w.lock.Unlock()
+
+	go w.run()
+	go newMountWatcher(w.root).run(w.closeCh, w.remount)
+}
+
+// remount watches whatever is now at path after a filesystem was mounted or
+// unmounted there, and rescans path so the index matches it. The watches on
+// an unmounted filesystem are dropped by the kernel.
+func (w *InotifyWatcher) remount(path string) {
+	w.lock.Lock()
+	w.addRecursive(path, false)
+	w.lock.Unlock()
+
+	select {
+	case w.stream <- []Event{{Path: path, Type: EventTypeRescan, Dir: true}}:
+	case <-w.closeCh:
+	}
+}
+
+func (w *InotifyWatcher) run() {
+	buf := make([]byte, (unix.SizeofInotifyEvent+unix.NAME_MAX+1)*256)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.logger.Error().Err(err).Msg("error reading inotify events")
+			}
+			return
+		}
+
+		w.handleEvents(buf[:n])
+	}
+}
+
+func (w *InotifyWatcher) handleEvents(buf []byte) {
+	w.lock.Lock()
+	translated := w.translateAll(buf)
+	w.lock.Unlock()
+
+	if len(translated) == 0 {
+		return
+	}
+
+	select {
+	case w.stream <- translated:
+	case <-w.closeCh:
+	}
+}
+
+func (w *InotifyWatcher) translateAll(buf []byte) []Event {
+	translated := []Event{}
+
+	// moves maps the cookie of an IN_MOVED_FROM to its Event, so the
+	// matching IN_MOVED_TO can turn it into a rename. A pair split across two
+	// reads ends up as a delete and an add, which is slower but still
+	// correct.
+	moves := map[uint32]int{}
+
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		name := strings.TrimRight(string(buf[nameStart:nameEnd]), "\x00")
+		offset = nameEnd
+
+		if raw.Mask&unix.IN_MOVED_TO != 0 {
+			if i, ok := moves[raw.Cookie]; ok {
+				if renamed, ok := w.rename(translated[i], raw, name); ok {
+					translated[i] = renamed
+					delete(moves, raw.Cookie)
+					continue
+				}
+			}
+		}
+
+		events := w.translate(raw, name)
+		if raw.Mask&unix.IN_MOVED_FROM != 0 && len(events) == 1 {
+			moves[raw.Cookie] = len(translated)
+		}
+		translated = append(translated, events...)
+	}
+
+	// Directories moved out from under root keep their watches, remove those
+	// so they don't keep reporting under the old path.
+	for _, i := range moves {
+		if translated[i].Dir {
+			w.removeRecursive(translated[i].Path)
+		}
+	}
+
+	return translated
+}
+
+// rename turns the delete Event from an IN_MOVED_FROM into a rename to the
+// destination of its matching IN_MOVED_TO.
+func (w *InotifyWatcher) rename(from Event, raw *unix.InotifyEvent, name string) (Event, bool) {
+	dir, ok := w.watches[int(raw.Wd)]
+	if !ok {
+		return from, false
+	}
+
+	e := Event{
+		Path:    filepath.Join(dir, name),
+		OldPath: from.Path,
+		Type:    EventTypeRename,
+		Dir:     from.Dir,
+	}
+	w.logger.Trace().Str("from", e.OldPath).Str("to", e.Path).Msg("renamed")
+
+	// The watches below a renamed directory stay valid, only their paths
+	// change.
+	if e.Dir {
+		moved := map[string]int{}
+		for path, wd := range w.paths {
+			if isUnder(e.OldPath, path) {
+				moved[e.Path+path[len(e.OldPath):]] = wd
+				delete(w.paths, path)
+			}
+		}
+
+		for path, wd := range moved {
+			w.watches[wd] = path
+			w.paths[path] = wd
+		}
+	}
+
+	return e, true
+}
+
+// translate turns a single inotify event into zero or more Events. Creating
+// or moving in a directory can produce many Events, as the new subtree has to
+// be registered and anything already inside of it reported.
+func (w *InotifyWatcher) translate(raw *unix.InotifyEvent, name string) []Event {
+	mask := raw.Mask
+
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.logger.Error().Str("root", w.root).Msg("inotify queue overflowed, rescanning")
+
+		// Any directories created while events were being dropped aren't
+		// watched yet. Existing watches are left as they are.
+		w.addRecursive(w.root, false)
+		return []Event{{Path: w.root, Type: EventTypeRescan, Dir: true}}
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		w.forget(int(raw.Wd))
+		return nil
+	}
+
+	dir, ok := w.watches[int(raw.Wd)]
+	if !ok {
+		w.logger.Trace().Int32("wd", raw.Wd).Str("name", name).Msg("event for unknown watch, skipping")
+		return nil
+	}
+
+	e := Event{
+		Path: filepath.Join(dir, name),
+		Dir:  mask&unix.IN_ISDIR != 0,
+	}
+
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		w.logger.Trace().Str("path", e.Path).Uint32("mask", mask).Msg("created")
+		e.Type = EventTypeAdd
+
+		if w.follow && !e.Dir {
+			if d, err := walk.Entry(e.Path, true); err == nil {
+				e.Dir = d.IsDir()
+			}
+		}
+
+		if e.Dir {
+			return append([]Event{e}, w.addRecursive(e.Path, true)...)
+		}
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		w.logger.Trace().Str("path", e.Path).Uint32("mask", mask).Msg("removed")
+		e.Type = EventTypeDelete
+
+		// A followed link is watched like a directory. Removing the link
+		// leaves its target, and so the watches, in place.
+		if _, ok := w.paths[e.Path]; ok && !e.Dir {
+			e.Dir = true
+			if mask&unix.IN_DELETE != 0 {
+				w.removeRecursive(e.Path)
+			}
+		}
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		w.logger.Trace().Str("path", e.Path).Uint32("mask", mask).Msg("modified")
+		e.Type = EventTypeModify
+	default:
+		w.logger.Warn().Str("path", e.Path).Uint32("mask", mask).Msg("Unknown event type, skipping")
+		return nil
+	}
+
+	return []Event{e}
+}
+
+// addRecursive registers a watch on root and every directory below it. If
+// emit is true an add Event is returned for everything found below root; this
+// covers entries created before the watch on their parent was in place.
+func (w *InotifyWatcher) addRecursive(root string, emit bool) []Event {
+	events := []Event{}
+
+	walk.Walk(root, w.follow, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			w.logger.Debug().Err(err).Str("path", path).Msg("error walking, skipping")
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if emit && path != root {
+			events = append(events, Event{Path: path, Type: EventTypeAdd, Dir: d.IsDir()})
+		}
+
+		if w.boundary.Excluded(path, d) {
+			w.logger.Debug().Str("path", path).Msg("filesystem excluded, not watching")
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() {
+			if err := w.addWatch(path, d.Type()&os.ModeSymlink != 0); err != nil {
+				w.logger.Error().Err(err).Str("path", path).Msg("unable to watch directory")
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+
+	return events
+}
+
+// addWatch watches the directory at path. If link is set path is a symlink,
+// and the directory it points to is watched instead.
+func (w *InotifyWatcher) addWatch(path string, link bool) error {
+	mask := uint32(inotifyMask)
+	if link {
+		mask &^= unix.IN_DONT_FOLLOW
+	}
+
+	wd, err := unix.InotifyAddWatch(w.fd, path, mask)
+	if err != nil {
+		if errors.Is(err, unix.ENOSPC) {
+			return errors.New("inotify watch limit reached; raise fs.inotify.max_user_watches")
+		}
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	w.watches[wd] = path
+	w.paths[path] = wd
+	return nil
+}
+
+// removeRecursive drops the watches for root and every directory below it.
+func (w *InotifyWatcher) removeRecursive(root string) {
+	removed := map[int]bool{}
+	for path, wd := range w.paths {
+		if !isUnder(root, path) {
+			continue
+		}
+
+		delete(w.paths, path)
+		removed[wd] = true
+	}
+
+	// A directory reached through another symlink shares the watch, which
+	// has to stay for that path.
+	for path, wd := range w.paths {
+		if removed[wd] {
+			w.watches[wd] = path
+			delete(removed, wd)
+		}
+	}
+
+	for wd := range removed {
+		unix.InotifyRmWatch(w.fd, uint32(wd))
+		delete(w.watches, wd)
+	}
+}
+
+func (w *InotifyWatcher) forget(wd int) {
+	if path, ok := w.watches[wd]; ok {
+		// The path may have been watched again since, through a new mount.
+		if w.paths[path] == wd {
+			delete(w.paths, path)
+		}
+		delete(w.watches, wd)
+	}
+}
+
+func (w *InotifyWatcher) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+		w.file.Close()
+	})
+}
+
+func (w *InotifyWatcher) Stream() <-chan []Event {
+	return w.stream
+}
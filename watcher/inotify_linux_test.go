@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "inotify-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	w, err := New(tmp)
+	require.NoError(t, err)
+	w.Start()
+	defer w.Stop()
+
+	dir := filepath.Join(tmp, "dir")
+	file := filepath.Join(dir, "file.txt")
+
+	require.NoError(t, os.Mkdir(dir, 0755))
+	expectEvents(t, w, Event{Path: dir, Type: EventTypeAdd, Dir: true})
+
+	// The new directory must have been registered for file events to show up.
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeAdd})
+
+	require.NoError(t, os.WriteFile(file, []byte("more data"), 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeModify})
+
+	require.NoError(t, os.Remove(file))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeDelete})
+
+	// The watch on a renamed directory must follow it to its new path.
+	renamed := filepath.Join(tmp, "renamed")
+	require.NoError(t, os.Rename(dir, renamed))
+	expectEvents(t, w, Event{Path: renamed, OldPath: dir, Type: EventTypeRename, Dir: true})
+
+	moved := filepath.Join(renamed, "moved.txt")
+	require.NoError(t, os.WriteFile(moved, nil, 0644))
+	expectEvents(t, w, Event{Path: moved, Type: EventTypeAdd})
+
+	require.NoError(t, os.Remove(moved))
+	require.NoError(t, os.Remove(renamed))
+	expectEvents(t, w,
+		Event{Path: moved, Type: EventTypeDelete},
+		Event{Path: renamed, Type: EventTypeDelete, Dir: true},
+	)
+}
+
+func TestInotifyWatcherFollowSymlinks(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "inotify-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	root := filepath.Join(tmp, "root")
+	data := filepath.Join(tmp, "data")
+	require.NoError(t, os.Mkdir(root, 0755))
+	require.NoError(t, os.Mkdir(data, 0755))
+
+	existing := filepath.Join(root, "existing")
+	require.NoError(t, os.Symlink(data, existing))
+
+	w, err := openDefault(root, Options{FollowSymlinks: true})
+	require.NoError(t, err)
+	w.Start()
+	defer w.Stop()
+
+	// Changes in the target show up below the link.
+	require.NoError(t, os.WriteFile(filepath.Join(data, "file.txt"), nil, 0644))
+	expectEvents(t, w, Event{Path: filepath.Join(existing, "file.txt"), Type: EventTypeAdd})
+
+	// A new link is treated as a directory, and the target's contents are
+	// picked up.
+	added := filepath.Join(root, "added")
+	require.NoError(t, os.Symlink(data, added))
+	expectEvents(t, w,
+		Event{Path: added, Type: EventTypeAdd, Dir: true},
+		Event{Path: filepath.Join(added, "file.txt"), Type: EventTypeAdd},
+	)
+
+	// Loops are reported as plain symlinks.
+	loop := filepath.Join(root, "loop")
+	require.NoError(t, os.Symlink(root, loop))
+	expectEvents(t, w, Event{Path: loop, Type: EventTypeAdd})
+
+	require.NoError(t, os.Remove(added))
+	expectEvents(t, w, Event{Path: added, Type: EventTypeDelete, Dir: true})
+
+	// The other link to the same directory is still watched.
+	require.NoError(t, os.Remove(filepath.Join(data, "file.txt")))
+	expectEvents(t, w, Event{Path: filepath.Join(existing, "file.txt"), Type: EventTypeDelete})
+}
@@ -1,5 +1,11 @@
 package watcher
 
+import (
+	"fmt"
+
+	"github.com/keyneston/fscache/internal/walk"
+)
+
 type EventType int
 
 // Compile time guarantee that the New function has been implemented for the platform.
@@ -9,12 +15,35 @@ const (
 	EventUnknown EventType = iota
 	EventTypeAdd
 	EventTypeDelete
+	// EventTypeRescan means events were lost somewhere below Path, so
+	// whatever is known about that subtree has to be checked against disk.
+	EventTypeRescan
+	// EventTypeRename moves OldPath, and everything below it, to Path.
+	EventTypeRename
+	// EventTypeModify means the contents of the file at Path have changed.
+	EventTypeModify
+	// EventTypeSync is never emitted by a real watcher. It is acknowledged,
+	// with Ack, once everything before it has been applied. See Fake.Sync.
+	EventTypeSync
 )
 
 type Event struct {
-	Path string
-	Type EventType
-	Dir  bool
+	Path string    `json:"path"`
+	Type EventType `json:"type"`
+	Dir  bool      `json:"dir,omitempty"`
+
+	// OldPath is where a renamed entry used to be. It is only set for
+	// EventTypeRename.
+	OldPath string `json:"old_path,omitempty"`
+
+	ack chan struct{}
+}
+
+// Ack acknowledges an EventTypeSync. It does nothing for other events.
+func (e Event) Ack() {
+	if e.ack != nil {
+		close(e.ack)
+	}
 }
 
 type Watcher interface {
@@ -22,3 +51,40 @@ type Watcher interface {
 	Stop()
 	Stream() <-chan []Event
 }
+
+// Kind selects which Watcher implementation Open creates.
+type Kind string
+
+const (
+	// KindDefault is the platform's native watcher, as returned by New.
+	KindDefault  Kind = "default"
+	KindFanotify Kind = "fanotify"
+	KindPoll     Kind = "poll"
+)
+
+// Options selects and configures the Watcher created by Open.
+type Options struct {
+	Kind Kind
+	Poll PollOptions
+
+	// FollowSymlinks watches the directories symlinks point to, reporting
+	// their events under the link's path. See walk.Walk.
+	FollowSymlinks bool
+	// Boundary limits which filesystems are watched. nil watches everything.
+	Boundary *walk.Boundary
+}
+
+func Open(root string, opts Options) (Watcher, error) {
+	switch opts.Kind {
+	case KindDefault, "":
+		return openDefault(root, opts)
+	case KindFanotify:
+		return openFanotify(root, opts)
+	case KindPoll:
+		opts.Poll.FollowSymlinks = opts.FollowSymlinks
+		opts.Poll.Boundary = opts.Boundary
+		return NewPoll(root, opts.Poll)
+	}
+
+	return nil, fmt.Errorf("Unknown watcher: %v", opts.Kind)
+}
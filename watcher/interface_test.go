@@ -1 +1,29 @@
 package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+// expectEvents reads from the watcher until it has seen every expected Event,
+// failing the test if they don't all arrive in time.
+func expectEvents(t *testing.T, w Watcher, expected ...Event) {
+	t.Helper()
+
+	remaining := map[Event]bool{}
+	for _, e := range expected {
+		remaining[e] = true
+	}
+
+	timeout := time.After(5 * time.Second)
+	for len(remaining) > 0 {
+		select {
+		case events := <-w.Stream():
+			for _, e := range events {
+				delete(remaining, e)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %+v", remaining)
+		}
+	}
+}
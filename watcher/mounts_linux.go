@@ -0,0 +1,204 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/rs/zerolog"
+	"golang.org/x/sys/unix"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// mountPollTimeout is the longest a mountWatcher waits before re-reading the
+// mount table, whether or not the kernel has flagged a change.
+var mountPollTimeout = time.Millisecond * 500
+
+// mountWatcher reports the mount point of every filesystem mounted or
+// unmounted below root. The kernel flags /proc/self/mountinfo with POLLPRI
+// when the mount table changes, which is used to pick up changes quickly.
+type mountWatcher struct {
+	root   string
+	file   *os.File
+	known  map[string]string
+	logger zerolog.Logger
+}
+
+// newMountWatcher reads the current mounts below root, so only changes made
+// after it returns are reported. It returns nil if mounts can't be watched.
+func newMountWatcher(root string) *mountWatcher {
+	logger := shared.Logger().With().Str("module", "mounts_linux").Logger()
+
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		logger.Warn().Err(err).Msg("unable to watch mounts")
+		return nil
+	}
+
+	known, err := readMounts(f, root)
+	if err != nil {
+		f.Close()
+		logger.Warn().Err(err).Msg("unable to watch mounts")
+		return nil
+	}
+
+	return &mountWatcher{root: root, file: f, known: known, logger: logger}
+}
+
+// run calls changed for each mount point that changes until closeCh is
+// closed. It does nothing on a nil mountWatcher.
+func (m *mountWatcher) run(closeCh <-chan bool, changed func(path string)) {
+	if m == nil {
+		return
+	}
+	defer m.file.Close()
+
+	fds := []unix.PollFd{{Fd: int32(m.file.Fd()), Events: unix.POLLPRI}}
+	for {
+		select {
+		case <-closeCh:
+			return
+		default:
+		}
+
+		// The table is re-read on timeouts as well, as the kernel doesn't
+		// always flag a change, or flags it before the table shows it.
+		_, err := unix.Poll(fds, int(mountPollTimeout/time.Millisecond))
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			m.logger.Error().Err(err).Msg("error polling mounts, no longer watching them")
+			return
+		}
+
+		if err := m.check(changed); err != nil {
+			m.logger.Error().Err(err).Msg("error re-reading mounts, no longer watching them")
+			return
+		}
+	}
+}
+
+func (m *mountWatcher) check(changed func(path string)) error {
+	if _, err := m.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	current, err := readMounts(m.file, m.root)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range diffMounts(m.known, current) {
+		m.logger.Info().Str("path", path).Msg("mounts changed")
+		changed(path)
+	}
+	m.known = current
+
+	return nil
+}
+
+// mountsUnder lists the mount points strictly below root.
+func mountsUnder(root string) ([]string, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts, err := readMounts(f, root)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, path := range mounts {
+		if !seen[path] {
+			seen[path] = true
+			res = append(res, path)
+		}
+	}
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// readMounts parses mountinfo, see proc(5), returning the mount point of
+// every mount strictly below root keyed by its mount ID. The ID tells apart
+// filesystems mounted over each other at the same path.
+func readMounts(r io.Reader, root string) (map[string]string, error) {
+	mounts := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// The first field is the mount ID and the fifth the mount point.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		mount := unescapeMountPath(fields[4])
+		if mount != root && isUnder(root, mount) {
+			mounts[fields[0]] = mount
+		}
+	}
+
+	return mounts, scanner.Err()
+}
+
+// diffMounts returns the mount points which were added or removed, sorted and
+// without duplicates.
+func diffMounts(before, after map[string]string) []string {
+	changed := map[string]bool{}
+	for id, path := range before {
+		if _, ok := after[id]; !ok {
+			changed[path] = true
+		}
+	}
+	for id, path := range after {
+		if _, ok := before[id]; !ok {
+			changed[path] = true
+		}
+	}
+
+	res := make([]string, 0, len(changed))
+	for path := range changed {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// unescapeMountPath decodes the octal escapes, such as \040 for a space, used
+// in /proc/self/mountinfo.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestReadMounts(t *testing.T) {
+	mountinfo := strings.Join([]string{
+		`28 1 254:0 / / rw,relatime - ext4 /dev/vda rw`,
+		`40 28 0:30 / /home/user/mnt rw - tmpfs tmpfs rw`,
+		`41 40 0:31 / /home/user/mnt rw - tmpfs tmpfs rw`,
+		`42 28 0:32 / /home/user/foo\040bar rw - fuse.sshfs remote: rw`,
+		`43 28 0:33 / /home/username rw - nfs server:/ rw`,
+	}, "\n")
+
+	mounts, err := readMounts(strings.NewReader(mountinfo), "/home/user")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"40": "/home/user/mnt",
+		"41": "/home/user/mnt",
+		"42": "/home/user/foo bar",
+	}, mounts)
+
+	// Unmounting the top of a stacked mount leaves the path mounted, but is
+	// still a change.
+	after := map[string]string{"40": "/home/user/mnt", "44": "/home/user/new"}
+	assert.Equal(t, []string{"/home/user/foo bar", "/home/user/mnt", "/home/user/new"}, diffMounts(mounts, after))
+	assert.Empty(t, diffMounts(mounts, mounts))
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	assert.Equal(t, "/mnt/foo bar", unescapeMountPath(`/mnt/foo\040bar`))
+	assert.Equal(t, "/mnt/plain", unescapeMountPath("/mnt/plain"))
+	assert.Equal(t, `/mnt/trailing\04`, unescapeMountPath(`/mnt/trailing\04`))
+}
+
+func TestInotifyWatcherMounts(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "inotify-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	mnt := filepath.Join(tmp, "mnt")
+	require.NoError(t, os.Mkdir(mnt, 0755))
+
+	w, err := New(tmp)
+	require.NoError(t, err)
+	w.Start()
+	defer w.Stop()
+
+	if err := unix.Mount("tmpfs", mnt, "tmpfs", 0, ""); err != nil {
+		t.Skipf("unable to mount tmpfs: %v", err)
+	}
+	defer unix.Unmount(mnt, unix.MNT_DETACH)
+	expectEvents(t, w, Event{Path: mnt, Type: EventTypeRescan, Dir: true})
+
+	// The new filesystem is watched.
+	file := filepath.Join(mnt, "file.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeAdd})
+
+	require.NoError(t, unix.Unmount(mnt, 0))
+	expectEvents(t, w, Event{Path: mnt, Type: EventTypeRescan, Dir: true})
+
+	// And once it is gone, the directory underneath is watched again.
+	file = filepath.Join(mnt, "under.txt")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	expectEvents(t, w, Event{Path: file, Type: EventTypeAdd})
+}
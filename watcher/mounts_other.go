@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package watcher
+
+// mountWatcher is only implemented on linux. Elsewhere mounts are left to be
+// picked up by the watchers themselves.
+type mountWatcher struct{}
+
+func newMountWatcher(root string) *mountWatcher {
+	return nil
+}
+
+func (m *mountWatcher) run(closeCh <-chan bool, changed func(path string)) {}
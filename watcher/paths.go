@@ -0,0 +1,20 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isUnder reports whether path is root or somewhere below it.
+func isUnder(root, path string) bool {
+	if path == root {
+		return true
+	}
+
+	prefix := root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+
+	return strings.HasPrefix(path, prefix)
+}
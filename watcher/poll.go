@@ -0,0 +1,399 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/keyneston/fscache/internal/walk"
+	"github.com/rs/zerolog"
+)
+
+var DefaultPollInterval = time.Second * 2
+
+// racyWindow is how close to a scan a directory's mtime has to be before it is
+// no longer trusted. Filesystems with coarse timestamps can record a later
+// change with the same mtime as the scan that preceded it.
+var racyWindow = time.Second
+
+type PollOptions struct {
+	// Interval is the time between scans. Defaults to DefaultPollInterval.
+	Interval time.Duration
+	// Budget is the maximum number of directories checked per scan. Anything
+	// left over is picked up by the following scans. 0 checks every directory
+	// on every scan.
+	Budget int
+	// FollowSymlinks treats symlinks to directories as the directories they
+	// point to.
+	FollowSymlinks bool
+	// Boundary limits which filesystems are polled. nil polls everything.
+	Boundary *walk.Boundary
+}
+
+// PollWatcher finds changes by periodically checking every directory under
+// root against the previous scan. It works anywhere, including network and
+// FUSE filesystems which never deliver kernel notifications.
+//
+// Only directories whose mtime has changed are re-read, as adding, removing
+// or renaming an entry updates its parent's mtime. Renames are only spotted
+// within a single directory, by matching up the file identities of removed
+// and added entries. Writing to a file doesn't touch its directory, so the
+// files themselves are stat'd to find modifications.
+type PollWatcher struct {
+	root string
+	opts PollOptions
+
+	// dirs is only touched by Start and then the run goroutine, so it doesn't
+	// need a lock.
+	dirs  map[string]*polledDir
+	order []string
+
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+
+	logger *zerolog.Logger
+}
+
+type polledDir struct {
+	modTime   time.Time
+	scannedAt time.Time
+	entries   map[string]os.FileInfo
+}
+
+func NewPoll(root string, opts PollOptions) (Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPollInterval
+	}
+
+	logger := shared.Logger().With().Str("module", "poll").Logger()
+
+	return &PollWatcher{
+		root:      filepath.Clean(root),
+		opts:      opts,
+		dirs:      map[string]*polledDir{},
+		closeCh:   make(chan bool),
+		closeOnce: &sync.Once{},
+		stream:    make(chan []Event, 10),
+		logger:    &logger,
+	}, nil
+}
+
+func (w *PollWatcher) Start() {
+	w.discover(w.root, false)
+
+	mounts := make(chan string)
+	go newMountWatcher(w.root).run(w.closeCh, func(path string) {
+		select {
+		case mounts <- path:
+		case <-w.closeCh:
+		}
+	})
+
+	go w.run(mounts)
+}
+
+func (w *PollWatcher) run(mounts <-chan string) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.scan()
+		case path := <-mounts:
+			w.remount(path)
+		case <-w.closeCh:
+			return
+		}
+	}
+}
+
+// remount starts again on path after a filesystem was mounted or unmounted
+// there, and rescans it so the index matches.
+func (w *PollWatcher) remount(path string) {
+	w.forget(path, true)
+	w.discover(path, false)
+
+	select {
+	case w.stream <- []Event{{Path: path, Type: EventTypeRescan, Dir: true}}:
+	case <-w.closeCh:
+	}
+}
+
+// scan checks the next Budget directories, continuing from where the last
+// scan stopped.
+func (w *PollWatcher) scan() {
+	events := []Event{}
+
+	for checked := 0; w.opts.Budget <= 0 || checked < w.opts.Budget; checked++ {
+		if len(w.order) == 0 {
+			// Start the next pass, but not part way through a scan, so no
+			// directory is checked twice in one scan.
+			if checked > 0 {
+				break
+			}
+			w.order = w.sortedDirs()
+			if len(w.order) == 0 {
+				break
+			}
+		}
+
+		dir := w.order[0]
+		w.order = w.order[1:]
+
+		events = append(events, w.check(dir)...)
+	}
+
+	if len(events) == 0 {
+		return
+	}
+
+	select {
+	case w.stream <- events:
+	case <-w.closeCh:
+	}
+}
+
+func (w *PollWatcher) sortedDirs() []string {
+	dirs := make([]string, 0, len(w.dirs))
+	for dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// check re-reads dir if it has changed since it was last read and returns the
+// differences.
+func (w *PollWatcher) check(dir string) []Event {
+	state, ok := w.dirs[dir]
+	if !ok {
+		// Removed since the pass started.
+		return nil
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		// The parent directory's check will report the removal.
+		w.logger.Trace().Err(err).Str("dir", dir).Msg("unable to stat directory")
+		return nil
+	}
+
+	if info.ModTime().Equal(state.modTime) && state.scannedAt.Sub(state.modTime) > racyWindow {
+		return w.checkFiles(dir, state)
+	}
+
+	current, err := w.read(dir)
+	if err != nil {
+		w.logger.Debug().Err(err).Str("dir", dir).Msg("unable to read directory")
+		return nil
+	}
+
+	removed := map[string]os.FileInfo{}
+	modified := []Event{}
+	for name, old := range state.entries {
+		if now, ok := current[name]; ok && now.IsDir() == old.IsDir() {
+			if isModified(old, now) {
+				modified = append(modified, Event{Path: filepath.Join(dir, name), Type: EventTypeModify})
+			}
+			continue
+		}
+		removed[name] = old
+	}
+
+	added := []Event{}
+	for name, now := range current {
+		if old, ok := state.entries[name]; ok && now.IsDir() == old.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+
+		// A rename within dir shows up as one entry disappearing and another
+		// appearing for the same file.
+		if from, ok := findSameFile(removed, now); ok {
+			delete(removed, from)
+			added = append(added, w.rename(filepath.Join(dir, from), path, now.IsDir()))
+			continue
+		}
+
+		added = append(added, Event{Path: path, Type: EventTypeAdd, Dir: now.IsDir()})
+		if now.IsDir() {
+			added = append(added, w.discover(path, true)...)
+		}
+	}
+
+	// Removals go first, an entry which changed type is in both.
+	events := []Event{}
+	for name, old := range removed {
+		events = append(events, w.forget(filepath.Join(dir, name), old.IsDir())...)
+	}
+	events = append(events, added...)
+	events = append(events, modified...)
+
+	state.modTime = info.ModTime()
+	state.scannedAt = time.Now()
+	state.entries = current
+
+	return events
+}
+
+// checkFiles looks for modified files in a directory which otherwise hasn't
+// changed.
+func (w *PollWatcher) checkFiles(dir string, state *polledDir) []Event {
+	events := []Event{}
+
+	for name, old := range state.entries {
+		if old.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		now, err := os.Lstat(path)
+		if err != nil {
+			// Removed, which the next check of dir will pick up.
+			continue
+		}
+
+		if isModified(old, now) {
+			events = append(events, Event{Path: path, Type: EventTypeModify})
+			state.entries[name] = now
+		}
+	}
+
+	return events
+}
+
+// discover records the state of root and every directory below it. If emit
+// is true an add Event is returned for everything found below root.
+func (w *PollWatcher) discover(root string, emit bool) []Event {
+	events := []Event{}
+
+	walk.Walk(root, w.opts.FollowSymlinks, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			w.logger.Debug().Err(err).Str("path", path).Msg("error walking, skipping")
+			return nil
+		}
+
+		if emit && path != root {
+			events = append(events, Event{Path: path, Type: EventTypeAdd, Dir: d.IsDir()})
+		}
+
+		if w.opts.Boundary.Excluded(path, d) {
+			w.logger.Debug().Str("path", path).Msg("filesystem excluded, not polling")
+			return filepath.SkipDir
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		entries, err := w.read(path)
+		if err != nil {
+			w.logger.Debug().Err(err).Str("path", path).Msg("unable to read directory")
+			return nil
+		}
+
+		w.dirs[path] = &polledDir{
+			modTime:   info.ModTime(),
+			scannedAt: time.Now(),
+			entries:   entries,
+		}
+		return nil
+	})
+
+	return events
+}
+
+// rename moves the state for everything below from to to.
+func (w *PollWatcher) rename(from, to string, dir bool) Event {
+	if dir {
+		moved := map[string]*polledDir{}
+		for known, state := range w.dirs {
+			if isUnder(from, known) {
+				moved[to+known[len(from):]] = state
+				delete(w.dirs, known)
+			}
+		}
+
+		for known, state := range moved {
+			w.dirs[known] = state
+		}
+	}
+
+	return Event{Path: to, OldPath: from, Type: EventTypeRename, Dir: dir}
+}
+
+// forget drops the state for path, and everything below it, and returns its
+// delete Event. Deleting a directory deletes its contents, so they don't get
+// Events of their own.
+func (w *PollWatcher) forget(path string, dir bool) []Event {
+	if dir {
+		for known := range w.dirs {
+			if isUnder(path, known) {
+				delete(w.dirs, known)
+			}
+		}
+	}
+
+	return []Event{{Path: path, Type: EventTypeDelete, Dir: dir}}
+}
+
+func (w *PollWatcher) read(dir string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]os.FileInfo, len(entries))
+	for _, e := range entries {
+		if w.opts.FollowSymlinks && e.Type()&os.ModeSymlink != 0 {
+			if followed, err := walk.Entry(filepath.Join(dir, e.Name()), true); err == nil {
+				e = followed
+			}
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			// Removed between reading the directory and the lstat.
+			continue
+		}
+		res[e.Name()] = info
+	}
+
+	return res, nil
+}
+
+func isModified(old, now os.FileInfo) bool {
+	return !old.IsDir() && (!now.ModTime().Equal(old.ModTime()) || now.Size() != old.Size())
+}
+
+func findSameFile(entries map[string]os.FileInfo, info os.FileInfo) (string, bool) {
+	for name, e := range entries {
+		if os.SameFile(e, info) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func (w *PollWatcher) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+	})
+}
+
+func (w *PollWatcher) Stream() <-chan []Event {
+	return w.stream
+}
@@ -0,0 +1,47 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollWatcher(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "poll-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	existing := filepath.Join(tmp, "existing")
+	require.NoError(t, os.Mkdir(existing, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(existing, "old.txt"), nil, 0644))
+
+	w, err := NewPoll(tmp, PollOptions{Interval: 10 * time.Millisecond, Budget: 1})
+	require.NoError(t, err)
+	w.Start()
+	defer w.Stop()
+
+	dir := filepath.Join(tmp, "dir")
+	file := filepath.Join(dir, "file.txt")
+
+	require.NoError(t, os.Mkdir(dir, 0755))
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	expectEvents(t, w,
+		Event{Path: dir, Type: EventTypeAdd, Dir: true},
+		Event{Path: file, Type: EventTypeAdd},
+	)
+
+	renamed := filepath.Join(tmp, "renamed")
+	require.NoError(t, os.Rename(dir, renamed))
+	expectEvents(t, w, Event{Path: renamed, OldPath: dir, Type: EventTypeRename, Dir: true})
+
+	// Only the file changes, not the directory it is in.
+	moved := filepath.Join(renamed, "file.txt")
+	require.NoError(t, os.WriteFile(moved, []byte("more data"), 0644))
+	expectEvents(t, w, Event{Path: moved, Type: EventTypeModify})
+
+	require.NoError(t, os.RemoveAll(existing))
+	expectEvents(t, w, Event{Path: existing, Type: EventTypeDelete, Dir: true})
+}
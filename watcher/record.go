@@ -0,0 +1,158 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/keyneston/fscache/internal/shared"
+	"github.com/rs/zerolog"
+)
+
+var _ Watcher = &Recorder{}
+
+var eventTypeNames = map[EventType]string{
+	EventUnknown:    "unknown",
+	EventTypeAdd:    "add",
+	EventTypeDelete: "delete",
+	EventTypeRescan: "rescan",
+	EventTypeRename: "rename",
+	EventTypeModify: "modify",
+	EventTypeSync:   "sync",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	for typ, name := range eventTypeNames {
+		if name == string(text) {
+			*t = typ
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown event type: %q", text)
+}
+
+// Batch is one batch of events from a Watcher's Stream, as written by a
+// Recorder.
+type Batch struct {
+	Time   time.Time `json:"time"`
+	Events []Event   `json:"events"`
+}
+
+// Recorder wraps a Watcher and writes every batch it emits to out, one JSON
+// object per line, before passing it on unchanged. A recording can be read
+// back with ReadRecording.
+//
+// If writing fails the error is logged and recording stops, but events keep
+// being passed on.
+type Recorder struct {
+	watcher Watcher
+	enc     *json.Encoder
+
+	closeCh   chan bool
+	closeOnce *sync.Once
+	stream    chan []Event
+
+	logger zerolog.Logger
+}
+
+func NewRecorder(w Watcher, out io.Writer) *Recorder {
+	return &Recorder{
+		watcher:   w,
+		enc:       json.NewEncoder(out),
+		closeCh:   make(chan bool),
+		closeOnce: &sync.Once{},
+		stream:    make(chan []Event),
+		logger:    shared.Logger().With().Str("module", "recorder").Logger(),
+	}
+}
+
+func (r *Recorder) Start() {
+	r.watcher.Start()
+
+	go r.run()
+}
+
+func (r *Recorder) run() {
+	for {
+		select {
+		case events := <-r.watcher.Stream():
+			r.record(events)
+
+			select {
+			case r.stream <- events:
+			case <-r.closeCh:
+				return
+			}
+		case <-r.closeCh:
+			return
+		}
+	}
+}
+
+func (r *Recorder) record(events []Event) {
+	if r.enc == nil {
+		return
+	}
+
+	batch := Batch{Time: time.Now().UTC(), Events: make([]Event, 0, len(events))}
+	for _, e := range events {
+		// Syncs only come from tests, and mean nothing in a replay.
+		if e.Type != EventTypeSync {
+			batch.Events = append(batch.Events, e)
+		}
+	}
+	if len(batch.Events) == 0 {
+		return
+	}
+
+	if err := r.enc.Encode(batch); err != nil {
+		r.logger.Error().Err(err).Msg("error writing recording, no longer recording")
+		r.enc = nil
+	}
+}
+
+func (r *Recorder) Stop() {
+	r.closeOnce.Do(func() {
+		r.watcher.Stop()
+		close(r.closeCh)
+	})
+}
+
+func (r *Recorder) Stream() <-chan []Event {
+	return r.stream
+}
+
+// ReadRecording calls fn, in order, for every batch in a recording written by
+// a Recorder. It stops at the first error from fn and returns it.
+func ReadRecording(in io.Reader, fn func(Batch) error) error {
+	dec := json.NewDecoder(in)
+	for {
+		var batch Batch
+		err := dec.Decode(&batch)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading recording: %w", err)
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	f := NewFake()
+	out := &bytes.Buffer{}
+	r := NewRecorder(f, out)
+	r.Start()
+	defer r.Stop()
+
+	batches := [][]Event{
+		{
+			{Path: "/a/dir", Type: EventTypeAdd, Dir: true},
+			{Path: "/a/dir/f", Type: EventTypeAdd},
+		},
+		{{Path: "/a/g", OldPath: "/a/dir/f", Type: EventTypeRename}},
+		{{Type: EventTypeSync}},
+		{{Path: "/a/g", Type: EventTypeModify}},
+	}
+
+	go func() {
+		for _, events := range batches {
+			f.Send(events...)
+		}
+	}()
+	for _, events := range batches {
+		assert.Equal(t, events, <-r.Stream(), "events are passed on unchanged")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3, "syncs aren't recorded")
+	assert.Contains(t, lines[1], `"events":[{"path":"/a/g","type":"rename","old_path":"/a/dir/f"}]`)
+
+	read := [][]Event{}
+	err := ReadRecording(out, func(batch Batch) error {
+		assert.False(t, batch.Time.IsZero())
+		read = append(read, batch.Events)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]Event{batches[0], batches[1], batches[3]}, read)
+}
+
+func TestReadRecordingInvalid(t *testing.T) {
+	in := strings.NewReader(`{"time":"2021-06-01T12:00:00Z","events":[{"path":"/a","type":"explode"}]}`)
+	err := ReadRecording(in, func(Batch) error { return nil })
+	assert.Error(t, err)
+}